@@ -0,0 +1,23 @@
+package mobilenig
+
+import "context"
+
+// BillsAPI is the interface of the `/bills/` endpoints. It is implemented by BillsService and MultiBillsService.
+// Depend on it instead of *Client so that a fake e.g. mobilenigtest.FakeBills can be used in tests.
+type BillsAPI interface {
+	CheckDStvUser(ctx context.Context, smartcardNumber string) (*DStvUser, *Response, error)
+	GetDStvPackage(ctx context.Context, customerNumber int64) (*string, *Response, error)
+	PayDStv(ctx context.Context, options *PayDstvOptions) (*DStvTransaction, *Response, error)
+	QueryDStv(ctx context.Context, transactionID string) (*DStvTransaction, *Response, error)
+}
+
+// WalletAPI is the interface of the `/balance` endpoint. It is implemented by WalletService.
+type WalletAPI interface {
+	GetBalance(ctx context.Context) (*WalletBalance, *Response, error)
+}
+
+var (
+	_ BillsAPI  = (*BillsService)(nil)
+	_ BillsAPI  = (*MultiBillsService)(nil)
+	_ WalletAPI = (*WalletService)(nil)
+)
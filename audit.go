@@ -0,0 +1,307 @@
+package mobilenig
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrAuditLogTampered is returned by VerifyAuditLog when the hash chain of an audit log is broken
+var ErrAuditLogTampered = errors.New("mobilenig: the audit log has been tampered with")
+
+// ErrAuditFailed is returned when a request or its response cannot be recorded with the AuditSink of the client
+var ErrAuditFailed = errors.New("mobilenig: cannot record the request in the audit log")
+
+const (
+	// AuditPhaseRequest is the phase of the record which is written before a request is sent
+	AuditPhaseRequest = "request"
+
+	// AuditPhaseResponse is the phase of the record which is written after the response of a request is received
+	AuditPhaseResponse = "response"
+)
+
+// AuditRecord is a request sent by the client or its response. Every request has a record in the AuditPhaseRequest
+// which is written before it is sent, and a record in the AuditPhaseResponse with the outcome.
+// Hash is the SHA-256 of the record with an empty Hash, and PrevHash is the Hash of the previous record.
+type AuditRecord struct {
+	Sequence     int64     `json:"sequence"`
+	Time         time.Time `json:"time"`
+	Phase        string    `json:"phase"`
+	Operation    Operation `json:"operation,omitempty"`
+	Method       string    `json:"method"`
+	URL          string    `json:"url"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	PrevHash     string    `json:"prev_hash"`
+	Hash         string    `json:"hash"`
+}
+
+// computeHash returns the hex encoded SHA-256 of the record with an empty Hash
+func (record AuditRecord) computeHash() (string, error) {
+	record.Hash = ""
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditSink records the request and response pairs sent by the client.
+// The Sequence, PrevHash and Hash of the record are set by the sink.
+type AuditSink interface {
+	Record(ctx context.Context, record *AuditRecord) error
+}
+
+// FileAuditSink is an AuditSink which appends hash-chained records as JSON lines to a file
+type FileAuditSink struct {
+	mu       sync.Mutex
+	file     *os.File
+	sequence int64
+	lastHash string
+}
+
+// NewFileAuditSink opens or creates the append-only audit log at path
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &FileAuditSink{file: file}
+	if err = sink.load(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+// load reads the sequence and hash of the last record in the audit log.
+// The audit log is never modified, so a log which ends with a partially written line e.g. after a crash is refused.
+func (sink *FileAuditSink) load() error {
+	var offset int64
+	reader := bufio.NewReader(sink.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				return fmt.Errorf("mobilenig: the audit log ends with a partial record at offset %d", offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		record := new(AuditRecord)
+		if err = json.Unmarshal(line, record); err != nil {
+			return fmt.Errorf("mobilenig: invalid audit record at offset %d: %w", offset, err)
+		}
+		sink.sequence, sink.lastHash = record.Sequence, record.Hash
+	}
+}
+
+// Record chains the record to the previous one and appends it to the audit log
+func (sink *FileAuditSink) Record(_ context.Context, record *AuditRecord) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	record.Sequence = sink.sequence + 1
+	record.PrevHash = sink.lastHash
+
+	hash, err := record.computeHash()
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err = sink.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if err = sink.file.Sync(); err != nil {
+		return err
+	}
+
+	sink.sequence, sink.lastHash = record.Sequence, record.Hash
+	return nil
+}
+
+// Head returns the sequence and hash of the last record in the audit log.
+// Store them outside of the audit log to detect removed records with VerifyAuditLogHead.
+func (sink *FileAuditSink) Head() (int64, string) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.sequence, sink.lastHash
+}
+
+// Close closes the audit log
+func (sink *FileAuditSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.file.Close()
+}
+
+// VerifyAuditLog checks the hash chain of the audit log at path.
+// The returned error matches ErrAuditLogTampered when a record has been modified, reordered or removed before the last
+// record. Records removed from the end of the log leave a valid chain, use VerifyAuditLogHead to detect them.
+func VerifyAuditLog(path string) error {
+	return verifyAuditLog(path, func(*AuditRecord) error { return nil })
+}
+
+// VerifyAuditLogHead checks the hash chain of the audit log at path like VerifyAuditLog, and that it contains the
+// record with the sequence and hash returned by FileAuditSink.Head when they were stored. Records added afterwards are
+// allowed.
+func VerifyAuditLogHead(path string, sequence int64, hash string) error {
+	found := sequence == 0 && hash == ""
+	err := verifyAuditLog(path, func(record *AuditRecord) error {
+		if record.Sequence != sequence {
+			return nil
+		}
+		if record.Hash != hash {
+			return fmt.Errorf("%w: the hash of record %d is not the stored hash", ErrAuditLogTampered, sequence)
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("%w: the record %d has been removed", ErrAuditLogTampered, sequence)
+	}
+	return nil
+}
+
+// verifyAuditLog checks the hash chain of the audit log at path and calls visit with every record
+func verifyAuditLog(path string, visit func(record *AuditRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	var previous AuditRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		record := new(AuditRecord)
+		if err = json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return fmt.Errorf("%w: line %d is not a valid record: %v", ErrAuditLogTampered, line, err)
+		}
+
+		if record.Sequence != previous.Sequence+1 || record.PrevHash != previous.Hash {
+			return fmt.Errorf("%w: line %d does not follow record %d", ErrAuditLogTampered, line, previous.Sequence)
+		}
+
+		hash, err := record.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != record.Hash {
+			return fmt.Errorf("%w: the hash of line %d does not match its contents", ErrAuditLogTampered, line)
+		}
+
+		if err = visit(record); err != nil {
+			return err
+		}
+
+		previous = *record
+	}
+
+	return scanner.Err()
+}
+
+type operationContextKey struct{}
+
+// auditRequest records the request with the AuditSink of the client before it is sent.
+// The request must not be sent when it cannot be recorded.
+func (client *Client) auditRequest(req *http.Request) error {
+	if client.auditSink == nil {
+		return nil
+	}
+
+	if err := client.auditSink.Record(req.Context(), client.newAuditRecord(req, AuditPhaseRequest)); err != nil {
+		return fmt.Errorf("%w: [%s %s] was not sent: %v", ErrAuditFailed, req.Method, redactURL(req.URL, secretParams...), err)
+	}
+	return nil
+}
+
+// auditResponse records the outcome of the request with the AuditSink of the client.
+// A failure is passed to onAuditError, or returned when there is no onAuditError so that it is never ignored.
+func (client *Client) auditResponse(req *http.Request, resp *Response, err error) error {
+	if client.auditSink == nil {
+		return nil
+	}
+
+	record := client.newAuditRecord(req, AuditPhaseResponse)
+
+	if resp != nil && resp.HTTPResponse != nil {
+		record.StatusCode = resp.HTTPResponse.StatusCode
+	}
+
+	if resp != nil && resp.Body != nil {
+		record.ResponseBody = string(*resp.Body)
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	// The record must be written even when the request context has been cancelled.
+	auditErr := client.auditSink.Record(context.Background(), record)
+	if auditErr == nil {
+		return nil
+	}
+
+	auditErr = fmt.Errorf("%w: the response of [%s %s] was not recorded: %v", ErrAuditFailed, req.Method, record.URL, auditErr)
+	if client.onAuditError != nil {
+		client.onAuditError(auditErr)
+		return nil
+	}
+	return auditErr
+}
+
+// newAuditRecord returns a record of the request with the API key redacted
+func (client *Client) newAuditRecord(req *http.Request, phase string) *AuditRecord {
+	record := &AuditRecord{
+		Time:   time.Now().UTC(),
+		Phase:  phase,
+		Method: req.Method,
+		URL:    redactURL(req.URL, secretParams...),
+	}
+
+	record.Operation, _ = req.Context().Value(operationContextKey{}).(Operation)
+	return record
+}
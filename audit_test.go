@@ -0,0 +1,244 @@
+package mobilenig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/helpers"
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_AuditSink(t *testing.T) {
+	// Arrange
+	server := helpers.MakeTestServer(http.StatusOK, stubs.PayDstvBillResponse())
+	baseURL, _ := url.Parse(server.URL)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	sink, err := NewFileAuditSink(path)
+	assert.NoError(t, err)
+
+	client := New(WithBaseURL(baseURL), WithAPIKey("secret"), WithAuditSink(sink, nil))
+
+	// Act
+	_, _, err = client.Bills.PayDStv(context.Background(), &PayDstvOptions{SmartcardNumber: "4131953321"})
+	assert.NoError(t, err)
+	_, _, err = client.Bills.QueryDStv(context.Background(), "122790223")
+	assert.NoError(t, err)
+
+	// Assert
+	assert.NoError(t, sink.Close())
+	assert.NoError(t, VerifyAuditLog(path))
+
+	contents, _ := ioutil.ReadFile(path)
+	lines := splitLines(contents)
+	assert.Equal(t, 4, len(lines))
+	assert.NotContains(t, string(contents), "secret")
+	assert.Contains(t, lines[0], `"phase":"request"`)
+	assert.Contains(t, lines[0], `"operation":"PayDStv"`)
+	assert.NotContains(t, lines[0], `"status_code"`)
+	assert.Contains(t, lines[1], `"phase":"response"`)
+	assert.Contains(t, lines[1], `"status_code":200`)
+	assert.Contains(t, lines[3], `"sequence":4`)
+
+	// Teardown
+	server.Close()
+}
+
+// auditSinkFunc is an AuditSink which calls the function
+type auditSinkFunc func(ctx context.Context, record *AuditRecord) error
+
+func (fn auditSinkFunc) Record(ctx context.Context, record *AuditRecord) error {
+	return fn(ctx, record)
+}
+
+func TestClient_AuditSinkFailures(t *testing.T) {
+	failingPhase := func(phase string) AuditSink {
+		return auditSinkFunc(func(ctx context.Context, record *AuditRecord) error {
+			if record.Phase == phase {
+				return errors.New("disk is full")
+			}
+			return nil
+		})
+	}
+
+	t.Run("a payment is not sent when the request cannot be recorded", func(t *testing.T) {
+		// Arrange
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			requests++
+			_, _ = res.Write([]byte(stubs.PayDstvBillResponse()))
+		}))
+		baseURL, _ := url.Parse(server.URL)
+		ledger := NewMemoryLedgerStore()
+
+		var handled []error
+		client := New(WithBaseURL(baseURL), WithLedger(ledger), WithAuditSink(failingPhase(AuditPhaseRequest), func(err error) {
+			handled = append(handled, err)
+		}))
+
+		// Act
+		_, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "trans-1", SmartcardNumber: "4131953321"})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrAuditFailed))
+		assert.Equal(t, 0, requests)
+		assert.Empty(t, handled)
+
+		entry, ledgerErr := ledger.Get(context.Background(), "trans-1")
+		assert.NoError(t, ledgerErr)
+		assert.Equal(t, LedgerStatusFailed, entry.Status)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("a failure to record the response is returned without an error handler", func(t *testing.T) {
+		// Arrange
+		server := helpers.MakeTestServer(http.StatusOK, stubs.PayDstvBillResponse())
+		baseURL, _ := url.Parse(server.URL)
+		ledger := NewMemoryLedgerStore()
+		client := New(WithBaseURL(baseURL), WithLedger(ledger), WithAuditSink(failingPhase(AuditPhaseResponse), nil))
+
+		// Act
+		transaction, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "trans-1", SmartcardNumber: "4131953321"})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrAuditFailed))
+		assert.Nil(t, transaction)
+
+		entry, ledgerErr := ledger.Get(context.Background(), "trans-1")
+		assert.NoError(t, ledgerErr)
+		assert.Equal(t, LedgerStatusSucceeded, entry.Status)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("a failure to record the response is passed to the error handler", func(t *testing.T) {
+		// Arrange
+		server := helpers.MakeTestServer(http.StatusOK, stubs.PayDstvBillResponse())
+		baseURL, _ := url.Parse(server.URL)
+
+		var handled []error
+		client := New(WithBaseURL(baseURL), WithAuditSink(failingPhase(AuditPhaseResponse), func(err error) {
+			handled = append(handled, err)
+		}))
+
+		// Act
+		_, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{SmartcardNumber: "4131953321"})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(handled))
+		assert.True(t, errors.Is(handled[0], ErrAuditFailed))
+
+		// Teardown
+		server.Close()
+	})
+}
+
+func TestFileAuditSink(t *testing.T) {
+	newAuditLog := func(t *testing.T, records int) string {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		sink, err := NewFileAuditSink(path)
+		assert.NoError(t, err)
+
+		for i := 0; i < records; i++ {
+			assert.NoError(t, sink.Record(context.Background(), &AuditRecord{Method: http.MethodGet, URL: "https://mobilenig.com/API/bills/dstv"}))
+		}
+		assert.NoError(t, sink.Close())
+		return path
+	}
+
+	t.Run("the chain continues when the file is reopened", func(t *testing.T) {
+		// Arrange
+		path := newAuditLog(t, 2)
+
+		// Act
+		sink, err := NewFileAuditSink(path)
+		assert.NoError(t, err)
+		record := &AuditRecord{Method: http.MethodGet}
+		assert.NoError(t, sink.Record(context.Background(), record))
+		assert.NoError(t, sink.Close())
+
+		// Assert
+		assert.Equal(t, int64(3), record.Sequence)
+		assert.NoError(t, VerifyAuditLog(path))
+	})
+
+	t.Run("a modified record is detected", func(t *testing.T) {
+		// Arrange
+		path := newAuditLog(t, 3)
+		contents, _ := ioutil.ReadFile(path)
+		contents = bytes.Replace(contents, []byte("bills/dstv"), []byte("bills/dstx"), 1)
+		assert.NoError(t, ioutil.WriteFile(path, contents, 0o600))
+
+		// Act
+		err := VerifyAuditLog(path)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrAuditLogTampered))
+	})
+
+	t.Run("a removed record is detected", func(t *testing.T) {
+		// Arrange
+		path := newAuditLog(t, 3)
+		contents, _ := ioutil.ReadFile(path)
+		lines := splitLines(contents)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(lines[0]+"\n"+lines[2]+"\n"), 0o600))
+
+		// Act
+		err := VerifyAuditLog(path)
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrAuditLogTampered))
+	})
+	t.Run("records removed from the end are detected with the stored head", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		sink, _ := NewFileAuditSink(path)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, sink.Record(context.Background(), &AuditRecord{Method: http.MethodGet}))
+		}
+		sequence, hash := sink.Head()
+		assert.NoError(t, sink.Close())
+
+		valid := VerifyAuditLogHead(path, sequence, hash)
+
+		contents, _ := ioutil.ReadFile(path)
+		lines := splitLines(contents)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(lines[0]+"\n"+lines[1]+"\n"), 0o600))
+
+		// Act
+		chainErr := VerifyAuditLog(path)
+		headErr := VerifyAuditLogHead(path, sequence, hash)
+
+		// Assert
+		assert.NoError(t, valid)
+		assert.NoError(t, chainErr)
+		assert.True(t, errors.Is(headErr, ErrAuditLogTampered))
+	})
+
+	t.Run("a log which ends with a partial record is refused", func(t *testing.T) {
+		// Arrange
+		path := newAuditLog(t, 2)
+		contents, _ := ioutil.ReadFile(path)
+		assert.NoError(t, ioutil.WriteFile(path, append(contents, []byte(`{"sequence":3,"ti`)...), 0o600))
+
+		// Act
+		_, err := NewFileAuditSink(path)
+
+		// Assert
+		assert.Error(t, err)
+		after, _ := ioutil.ReadFile(path)
+		assert.True(t, bytes.HasSuffix(after, []byte(`{"sequence":3,"ti`)))
+	})
+}
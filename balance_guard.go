@@ -0,0 +1,223 @@
+package mobilenig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// InsufficientBalanceError is returned when a payment would take the wallet balance below the configured floor.
+// errors.Is(err, ErrInsufficientBalance) returns true for this error.
+type InsufficientBalanceError struct {
+	Balance float64
+	Price   float64
+	Floor   float64
+}
+
+// Error returns the error message
+func (err *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf(
+		"%s: balance %.2f minus price %.2f is less than the floor %.2f",
+		ErrInsufficientBalance,
+		err.Balance,
+		err.Price,
+		err.Floor,
+	)
+}
+
+// Is makes errors.Is(err, ErrInsufficientBalance) return true
+func (err *InsufficientBalanceError) Is(target error) bool {
+	return target == ErrInsufficientBalance
+}
+
+// BalanceGuard configures the wallet balance check which is done before a payment is sent
+type BalanceGuard struct {
+	// Floor is the minimum balance which must remain in the wallet after a payment
+	Floor float64
+
+	// CacheTTL is how long a wallet balance is reused before it is fetched again.
+	// The balance is fetched before every payment when it is 0.
+	CacheTTL time.Duration
+
+	// LowBalanceThreshold is the balance below which OnLowBalance is called. It defaults to Floor.
+	LowBalanceThreshold float64
+
+	// OnLowBalance is called with the wallet balance when it drops below the LowBalanceThreshold
+	OnLowBalance func(balance float64)
+}
+
+// balanceCache caches the wallet balance for the BalanceGuard.
+// The generation changes every time the balance is fetched, so a refund never applies to a newer balance.
+type balanceCache struct {
+	mu         sync.Mutex
+	amount     float64
+	fetchedAt  time.Time
+	valid      bool
+	generation uint64
+}
+
+// store caches a balance which was fetched at fetchedAt unless a newer balance is already cached
+func (cache *balanceCache) store(amount float64, fetchedAt time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.valid && cache.fetchedAt.After(fetchedAt) {
+		return
+	}
+
+	cache.amount = amount
+	cache.fetchedAt = fetchedAt
+	cache.valid = true
+	cache.generation++
+}
+
+// get returns the cached balance if it is younger than ttl
+func (cache *balanceCache) get(ttl time.Duration) (float64, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.amount, cache.valid && time.Since(cache.fetchedAt) < ttl
+}
+
+// checkBalance ensures that the wallet balance minus the price stays above the floor of the BalanceGuard.
+// The price is deducted from the cached balance so that concurrent payments don't all pass the check, use
+// refundBalance to add it back when the payment is not sent or fails.
+func (client *Client) checkBalance(ctx context.Context, payment *payment) error {
+	guard := client.balanceGuard
+	if guard == nil {
+		return nil
+	}
+
+	amount, err := strconv.ParseFloat(payment.price, 64)
+	if err != nil {
+		return fmt.Errorf("mobilenig: invalid price [%s]: %w", payment.price, err)
+	}
+
+	balance, err := client.reserveBalance(ctx, payment, amount)
+	if err != nil {
+		return err
+	}
+
+	if balance-amount < guard.Floor {
+		client.notifyLowBalance(balance)
+		return &InsufficientBalanceError{Balance: balance, Price: amount, Floor: guard.Floor}
+	}
+
+	return nil
+}
+
+// reserveBalance returns the cached wallet balance, fetching it when the cache has expired.
+// The amount is deducted from the cached balance and recorded in the payment if the balance stays above the floor.
+func (client *Client) reserveBalance(ctx context.Context, payment *payment, amount float64) (float64, error) {
+	if _, fresh := client.balance.get(client.balanceGuard.CacheTTL); !fresh {
+		if err := client.fetchBalance(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	client.balance.mu.Lock()
+	defer client.balance.mu.Unlock()
+
+	balance := client.balance.amount
+	if balance-amount >= client.balanceGuard.Floor {
+		client.balance.amount -= amount
+		payment.balanceReserved, payment.balanceGeneration = amount, client.balance.generation
+	}
+
+	return balance, nil
+}
+
+// refundBalance adds the amount reserved for a payment which was not sent or failed back to the cached balance.
+// Nothing is added when the balance has been fetched again since the payment was checked.
+func (client *Client) refundBalance(payment *payment) {
+	if payment.balanceReserved == 0 {
+		return
+	}
+
+	client.balance.mu.Lock()
+	defer client.balance.mu.Unlock()
+
+	if client.balance.generation == payment.balanceGeneration {
+		client.balance.amount += payment.balanceReserved
+	}
+	payment.balanceReserved = 0
+}
+
+// fetchBalance fetches the wallet balance and caches it. The cache is not locked during the request.
+func (client *Client) fetchBalance(ctx context.Context) error {
+	fetchedAt := time.Now()
+	balance, _, err := client.Wallet.GetBalance(ctx)
+	if err != nil {
+		return err
+	}
+
+	amount, err := balance.Amount()
+	if err != nil {
+		return fmt.Errorf("mobilenig: invalid wallet balance [%s]: %w", balance.Balance, err)
+	}
+
+	client.balance.store(amount, fetchedAt)
+	return nil
+}
+
+// updateBalance caches the wallet balance returned in the response of a payment for the BalanceGuard and the
+// RoutingHighestBalance strategy of a MultiClient
+func (client *Client) updateBalance(resp *Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+
+	payload := new(struct {
+		Details struct {
+			Balance string `json:"balance"`
+		} `json:"details"`
+	})
+	if json.Unmarshal(*resp.Body, payload) != nil {
+		return
+	}
+
+	amount, err := strconv.ParseFloat(payload.Details.Balance, 64)
+	if err != nil {
+		return
+	}
+
+	client.balance.store(amount, time.Now())
+
+	if client.balanceGuard != nil {
+		client.notifyLowBalance(amount)
+	}
+}
+
+// cachedBalance returns the cached wallet balance, fetching it when it is older than ttl
+func (client *Client) cachedBalance(ctx context.Context, ttl time.Duration) (float64, error) {
+	if amount, fresh := client.balance.get(ttl); fresh {
+		return amount, nil
+	}
+
+	if err := client.fetchBalance(ctx); err != nil {
+		return 0, err
+	}
+
+	amount, _ := client.balance.get(ttl)
+	return amount, nil
+}
+
+// notifyLowBalance calls the OnLowBalance callback when the balance is below the LowBalanceThreshold
+func (client *Client) notifyLowBalance(balance float64) {
+	guard := client.balanceGuard
+	if guard.OnLowBalance == nil {
+		return
+	}
+
+	threshold := guard.LowBalanceThreshold
+	if threshold == 0 {
+		threshold = guard.Floor
+	}
+
+	if balance < threshold {
+		guard.OnLowBalance(balance)
+	}
+}
@@ -0,0 +1,136 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// makeBalanceGuardTestServer creates a server which responds with the wallet balance and counts the requests per path
+func makeBalanceGuardTestServer(balance string, requests map[string]int) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests[req.URL.Path]++
+		mu.Unlock()
+
+		if req.URL.Path == "/balance" {
+			_, _ = res.Write([]byte(`{"balance":"` + balance + `"}`))
+			return
+		}
+		_, _ = res.Write([]byte(`{"trans_id":"122790223","details":{"status":"SUCCESSFUL","price":"790","balance":"210"}}`))
+	}))
+}
+
+func TestClient_BalanceGuard_PaymentIsRefusedBelowFloor(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	requests := map[string]int{}
+	server := makeBalanceGuardTestServer("1000", requests)
+	baseURL, _ := url.Parse(server.URL)
+
+	var lowBalance float64
+	client := New(WithBaseURL(baseURL), WithBalanceGuard(BalanceGuard{
+		Floor:               500,
+		LowBalanceThreshold: 2000,
+		OnLowBalance:        func(balance float64) { lowBalance = balance },
+	}))
+
+	// Act
+	_, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{Price: "790"})
+
+	// Assert
+	assert.True(t, errors.Is(err, ErrInsufficientBalance))
+
+	balanceErr := new(InsufficientBalanceError)
+	assert.True(t, errors.As(err, &balanceErr))
+	assert.Equal(t, 1000.0, balanceErr.Balance)
+	assert.Equal(t, 790.0, balanceErr.Price)
+	assert.Equal(t, 500.0, balanceErr.Floor)
+
+	assert.Equal(t, 1000.0, lowBalance)
+	assert.Equal(t, 0, requests["/bills/dstv"])
+
+	// Teardown
+	server.Close()
+}
+
+func TestClient_BalanceGuard_CachedBalanceIsUpdatedFromPayments(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	requests := map[string]int{}
+	server := makeBalanceGuardTestServer("1000", requests)
+	baseURL, _ := url.Parse(server.URL)
+
+	var lowBalance float64
+	client := New(WithBaseURL(baseURL), WithBalanceGuard(BalanceGuard{
+		Floor:        100,
+		CacheTTL:     time.Hour,
+		OnLowBalance: func(balance float64) { lowBalance = balance },
+	}))
+
+	// Act
+	_, _, firstErr := client.Bills.PayDStv(context.Background(), &PayDstvOptions{Price: "790"})
+	_, _, secondErr := client.Bills.PayDStv(context.Background(), &PayDstvOptions{Price: "790"})
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.True(t, errors.Is(secondErr, ErrInsufficientBalance))
+	assert.Equal(t, 1, requests["/balance"])
+	assert.Equal(t, 1, requests["/bills/dstv"])
+	assert.Equal(t, 0.0, lowBalance)
+
+	// Teardown
+	server.Close()
+}
+
+func TestClient_BalanceGuard_ReservedBalanceIsRefunded(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	requests := map[string]int{}
+	server := makeBalanceGuardTestServer("1000", requests)
+	baseURL, _ := url.Parse(server.URL)
+
+	store := NewMemoryLedgerStore()
+	_ = store.Save(context.Background(), newTestLedgerEntry("122790223", LedgerStatusPending, time.Now()))
+	client := New(WithBaseURL(baseURL), WithLedger(store), WithBalanceGuard(BalanceGuard{Floor: 100, CacheTTL: time.Hour}))
+
+	// Act
+	_, _, refusedErr := client.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223", Price: "790"})
+	_, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{Price: "790"})
+
+	// Assert
+	assert.True(t, errors.Is(refusedErr, ErrTransactionAlreadyRecorded))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests["/balance"])
+	assert.Equal(t, 1, requests["/bills/dstv"])
+
+	// Teardown
+	server.Close()
+}
+
+func TestClient_BalanceGuard_InvalidPrice(t *testing.T) {
+	// Arrange
+	baseURL, _ := url.Parse("http://127.0.0.1:0")
+	client := New(WithBaseURL(baseURL), WithBalanceGuard(BalanceGuard{}))
+
+	// Act
+	_, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{Price: "abc"})
+
+	// Assert
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrInsufficientBalance))
+}
@@ -2,7 +2,6 @@ package mobilenig
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"strconv"
 )
@@ -18,12 +17,26 @@ const (
 // POST /bills/user_check
 // API Doc: https://mobilenig.com/API/docs/dstv
 func (service *BillsService) CheckDStvUser(ctx context.Context, smartcardNumber string) (*DStvUser, *Response, error) {
+	value, resp, err := service.client.deduplicate(ctx, OperationCheckDStvUser.String()+":"+smartcardNumber, func() (interface{}, *Response, error) {
+		return service.checkDStvUser(ctx, smartcardNumber)
+	})
+
+	user, _ := value.(*DStvUser)
+	if user != nil {
+		clone := *user
+		user = &clone
+	}
+
+	return user, resp, err
+}
+
+func (service *BillsService) checkDStvUser(ctx context.Context, smartcardNumber string) (*DStvUser, *Response, error) {
 	payload := map[string]string{
 		"service": billsServiceDStv,
 		"number":  smartcardNumber,
 	}
 
-	request, err := service.client.newRequest(ctx, "/bills/user_check", payload)
+	request, err := service.client.newRequest(ctx, endpointCheckDStvUser, payload)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -34,7 +47,7 @@ func (service *BillsService) CheckDStvUser(ctx context.Context, smartcardNumber
 	}
 
 	var dstvUser DStvUser
-	if err = json.Unmarshal(*resp.Body, &dstvUser); err != nil {
+	if err = service.client.decode(OperationCheckDStvUser, resp, &dstvUser); err != nil {
 		return nil, resp, err
 	}
 
@@ -50,7 +63,7 @@ func (service *BillsService) GetDStvPackage(ctx context.Context, customerNumber
 		"customerNumber": strconv.FormatInt(customerNumber, 10),
 	}
 
-	request, err := service.client.newRequest(ctx, "/bills/get_package", payload)
+	request, err := service.client.newRequest(ctx, endpointGetDStvPackage, payload)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -60,15 +73,17 @@ func (service *BillsService) GetDStvPackage(ctx context.Context, customerNumber
 		return nil, resp, err
 	}
 
-	details := map[string]*string{}
-	if err = json.Unmarshal(*resp.Body, &details); err != nil {
+	details := new(dstvPackageDetails)
+	if err = service.client.decode(OperationGetDStvPackage, resp, details); err != nil {
 		return nil, resp, err
 	}
 
-	return details["packageName"], resp, nil
+	return details.PackageName, resp, nil
 }
 
-// PayDStv pays a DStv subscription
+// PayDStv pays a DStv subscription.
+// When options.TransactionID is empty, a new transaction ID is generated and set on the options.
+// In dry-run mode, the options are validated and ErrDryRun is returned with the request in Response.DryRun.
 // POST /bills/dstv
 // API Doc: https://mobilenig.com/API/docs/dstv
 func (service *BillsService) PayDStv(ctx context.Context, options *PayDstvOptions) (*DStvTransaction, *Response, error) {
@@ -76,32 +91,32 @@ func (service *BillsService) PayDStv(ctx context.Context, options *PayDstvOption
 		return nil, nil, errors.New("options cannot be nil")
 	}
 
-	payload := map[string]string{
-		"product_code":    string(options.ProductCode),
-		"customer_name":   options.CustomerName,
-		"customer_number": options.CustomerNumber,
-		"price":           options.Price,
-		"smartno":         options.SmartcardNumber,
-		"trans_id":        options.TransactionID,
+	if options.TransactionID == "" {
+		transactionID, err := service.client.NewTransactionID()
+		if err != nil {
+			return nil, nil, err
+		}
+		options.TransactionID = transactionID
 	}
 
-	uri := "/bills/dstv"
-	if service.client.environment == TestEnvironment {
-		uri += "_test"
-	}
+	payload := options.params()
 
-	request, err := service.client.newRequest(ctx, uri, payload)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	resp, err := service.client.do(request)
+	resp, err := service.client.pay(ctx, &payment{
+		endpoint:        endpointPayDStv,
+		transactionID:   options.TransactionID,
+		price:           options.Price,
+		productCode:     string(options.ProductCode),
+		smartcardNumber: options.SmartcardNumber,
+		customerNumber:  options.CustomerNumber,
+		params:          payload,
+		validate:        options.Validate,
+	})
 	if err != nil {
 		return nil, resp, err
 	}
 
 	var transaction DStvTransaction
-	if err = json.Unmarshal(*resp.Body, &transaction); err != nil {
+	if err = service.client.decode(OperationPayDStv, resp, &transaction); err != nil {
 		return nil, resp, err
 	}
 
@@ -112,11 +127,25 @@ func (service *BillsService) PayDStv(ctx context.Context, options *PayDstvOption
 // POST /bills/dstv
 // API Doc: https://mobilenig.com/API/docs/dstv
 func (service *BillsService) QueryDStv(ctx context.Context, transactionID string) (*DStvTransaction, *Response, error) {
+	value, resp, err := service.client.deduplicate(ctx, OperationQueryDStv.String()+":"+transactionID, func() (interface{}, *Response, error) {
+		return service.queryDStv(ctx, transactionID)
+	})
+
+	transaction, _ := value.(*DStvTransaction)
+	if transaction != nil {
+		clone := *transaction
+		transaction = &clone
+	}
+
+	return transaction, resp, err
+}
+
+func (service *BillsService) queryDStv(ctx context.Context, transactionID string) (*DStvTransaction, *Response, error) {
 	payload := map[string]string{
 		"trans_id": transactionID,
 	}
 
-	request, err := service.client.newRequest(ctx, "/bills/query", payload)
+	request, err := service.client.newRequest(ctx, endpointQueryDStv, payload)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -127,7 +156,7 @@ func (service *BillsService) QueryDStv(ctx context.Context, transactionID string
 	}
 
 	var transaction DStvTransaction
-	if err = json.Unmarshal(*resp.Body, &transaction); err != nil {
+	if err = service.client.decode(OperationQueryDStv, resp, &transaction); err != nil {
 		return nil, resp, err
 	}
 
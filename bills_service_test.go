@@ -2,10 +2,14 @@ package mobilenig
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -53,6 +57,7 @@ func TestBillsService_CheckDStvUser_RequestConstructedCorrectly(t *testing.T) {
 
 	environments := []Environment{LiveEnvironment, TestEnvironment}
 	for _, environment := range environments {
+		environment := environment
 		t.Run(environment.String(), func(t *testing.T) {
 			// Setup
 			t.Parallel()
@@ -89,6 +94,7 @@ func TestBillsService_CheckDStvUser_ErrorResponseConstructedCorrectly(t *testing
 
 	environments := []Environment{LiveEnvironment, TestEnvironment}
 	for _, environment := range environments {
+		environment := environment
 		t.Run(environment.String(), func(t *testing.T) {
 			// Setup
 			t.Parallel()
@@ -120,6 +126,7 @@ func TestBillsService_CheckDStvUser_CancelledContext(t *testing.T) {
 
 	environments := []Environment{LiveEnvironment, TestEnvironment}
 	for _, environment := range environments {
+		environment := environment
 		t.Run(environment.String(), func(t *testing.T) {
 			t.Parallel()
 
@@ -144,6 +151,7 @@ func TestBillsService_CheckDStvUser_InvalidResponse(t *testing.T) {
 	t.Parallel()
 	environments := []Environment{LiveEnvironment, TestEnvironment}
 	for _, environment := range environments {
+		environment := environment
 		t.Run(environment.String(), func(t *testing.T) {
 			t.Parallel()
 
@@ -299,6 +307,7 @@ func TestBillsService_PayDStv_InvalidResponse(t *testing.T) {
 
 	environments := []Environment{LiveEnvironment, TestEnvironment}
 	for _, environment := range environments {
+		environment := environment
 		t.Run(environment.String(), func(t *testing.T) {
 			t.Parallel()
 
@@ -433,6 +442,7 @@ func TestBillsService_QueryDStv_CancelledContext(t *testing.T) {
 
 	environments := []Environment{LiveEnvironment, TestEnvironment}
 	for _, environment := range environments {
+		environment := environment
 		t.Run(environment.String(), func(t *testing.T) {
 			t.Parallel()
 
@@ -460,6 +470,7 @@ func TestBillsService_QueryDStv_InvalidResponse(t *testing.T) {
 
 	environments := []Environment{LiveEnvironment, TestEnvironment}
 	for _, environment := range environments {
+		environment := environment
 		t.Run(environment.String(), func(t *testing.T) {
 			t.Parallel()
 
@@ -530,3 +541,283 @@ func TestBillsService_GetDStvPackageRequest(t *testing.T) {
 	// Teardown
 	server.Close()
 }
+
+func TestBillsService_QueryDStv_ConcurrentRequestsAreDeduplicated(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		_, _ = res.Write([]byte(stubs.QueryDstvTransactionResponse()))
+	}))
+
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL))
+
+	callers := 10
+	transactions := make([]*DStvTransaction, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			transactions[i], _, errs[i] = client.Bills.QueryDStv(context.Background(), "122790223")
+		}(i)
+	}
+
+	// Act
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Assert
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	for i := 0; i < callers; i++ {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, "122790223", transactions[i].TransactionID)
+	}
+
+	transactions[0].Details.Status = "FAILED"
+	assert.Equal(t, "SUCCESSFUL", transactions[1].Details.Status)
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_CheckDStvUser_DeduplicationCanBeDisabled(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		_, _ = res.Write([]byte(stubs.CheckDstvUserResponse()))
+	}))
+
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL), WithRequestDeduplication(false))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = client.Bills.CheckDStvUser(context.Background(), "4131953321")
+		}()
+	}
+
+	// Act
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Assert
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_PayDStv_TransactionIDIsGenerated(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	request := new(http.Request)
+	server := helpers.MakeRequestCapturingTestServer(http.StatusOK, stubs.PayDstvBillResponse(), request)
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL))
+	options := &PayDstvOptions{SmartcardNumber: "4131953321"}
+
+	// Act
+	_, _, err := client.Bills.PayDStv(context.Background(), options)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, options.TransactionID, 26)
+	assert.Equal(t, options.TransactionID, request.URL.Query().Get("trans_id"))
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_PayDStv_DuplicateTransactionIDIsRefused(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = res.Write([]byte(stubs.PayDstvBillResponse()))
+	}))
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL), WithTransactionIDGuard(nil))
+
+	// Act
+	_, _, firstErr := client.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223"})
+	_, _, secondErr := client.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223"})
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.True(t, errors.Is(secondErr, ErrDuplicateTransactionID))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_PayDStv_RefusedTransactionIDCanBeRetried(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = res.Write([]byte(stubs.PayDstvBillResponse()))
+	}))
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL), WithTransactionIDGuard(nil), WithPaymentPolicy(&PaymentPolicy{MaxAmount: 1000}))
+
+	// Act
+	_, _, refusedErr := client.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223", Price: "5000"})
+	_, _, retryErr := client.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223", Price: "790"})
+	_, _, duplicateErr := client.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223", Price: "790"})
+
+	// Assert
+	assert.True(t, errors.Is(refusedErr, ErrPolicyViolation))
+	assert.NoError(t, retryErr)
+	assert.True(t, errors.Is(duplicateErr, ErrDuplicateTransactionID))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_PayDStv_PaymentIsRecordedInLedger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		body   string
+		status LedgerStatus
+	}{
+		{name: "successful payment", body: stubs.PayDstvBillResponse(), status: LedgerStatusSucceeded},
+		{name: "error response", body: stubs.ErrorResponse(), status: LedgerStatusFailed},
+		{name: "invalid response", body: "<not-a-json></not-a-json>", status: LedgerStatusUnknown},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			var intent *LedgerEntry
+			ledger := NewMemoryLedgerStore()
+			server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				intent, _ = ledger.Get(req.Context(), "122790223")
+				_, _ = res.Write([]byte(test.body))
+			}))
+			baseURL, _ := url.Parse(server.URL)
+			client := New(WithBaseURL(baseURL), WithLedger(ledger))
+
+			// Act
+			_, _, _ = client.Bills.PayDStv(context.Background(), &PayDstvOptions{
+				TransactionID:   "122790223",
+				Price:           "790",
+				SmartcardNumber: "4131953321",
+			})
+
+			// Assert
+			assert.Equal(t, LedgerStatusPending, intent.Status)
+			assert.Equal(t, "PayDStv", intent.Operation)
+			assert.Equal(t, "790", intent.Request["price"])
+			assert.Empty(t, intent.Request["api_key"])
+
+			entry, err := ledger.Get(context.Background(), "122790223")
+			assert.NoError(t, err)
+			assert.Equal(t, test.status, entry.Status)
+			assert.Equal(t, "4131953321", entry.Request["smartno"])
+			assert.True(t, !entry.UpdatedAt.Before(entry.CreatedAt))
+
+			// Teardown
+			server.Close()
+		})
+	}
+}
+
+func TestBillsService_PayDStv_RecordedTransactionIsNotSentAgain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status LedgerStatus
+		sent   bool
+	}{
+		{status: LedgerStatusSucceeded, sent: false},
+		{status: LedgerStatusUnknown, sent: false},
+		{status: LedgerStatusPending, sent: false},
+		{status: LedgerStatusFailed, sent: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.status.String(), func(t *testing.T) {
+			t.Parallel()
+
+			// Arrange
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				_, _ = res.Write([]byte(stubs.PayDstvBillResponse()))
+			}))
+			baseURL, _ := url.Parse(server.URL)
+
+			ledger := NewMemoryLedgerStore()
+			existing := &LedgerEntry{
+				TransactionID: "122790223",
+				Operation:     OperationPayDStv.String(),
+				Status:        test.status,
+				Request:       map[string]string{"trans_id": "122790223", "price": "790"},
+				Response:      json.RawMessage(`{"trans_id":"122790223"}`),
+				CreatedAt:     time.Now().UTC(),
+				UpdatedAt:     time.Now().UTC(),
+			}
+			assert.NoError(t, ledger.Save(context.Background(), existing))
+			client := New(WithBaseURL(baseURL), WithLedger(ledger))
+
+			// Act
+			_, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{
+				TransactionID:   "122790223",
+				Price:           "790",
+				SmartcardNumber: "4131953321",
+			})
+
+			// Assert
+			entry, _ := ledger.Get(context.Background(), "122790223")
+			if test.sent {
+				assert.NoError(t, err)
+				assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+				assert.Equal(t, LedgerStatusSucceeded, entry.Status)
+			} else {
+				assert.True(t, errors.Is(err, ErrTransactionAlreadyRecorded))
+				assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+				assert.Equal(t, test.status, entry.Status)
+				assert.JSONEq(t, `{"trans_id":"122790223"}`, string(entry.Response))
+			}
+
+			// Teardown
+			server.Close()
+		})
+	}
+}
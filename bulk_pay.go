@@ -0,0 +1,144 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// ErrBulkPaySkipped is the error of a BulkPay item which was not sent because the batch was stopped
+var ErrBulkPaySkipped = errors.New("mobilenig: payment skipped because the batch was stopped")
+
+// BulkPayOptions configures BillsService.BulkPay
+type BulkPayOptions struct {
+	// Concurrency is the maximum number of payments which are sent at the same time. Defaults to 4.
+	Concurrency int
+
+	// StopOnError stops the batch after the first failed payment. Payments which were not sent get ErrBulkPaySkipped.
+	StopOnError bool
+
+	// SkipBalanceCheck disables the wallet balance check which is done before any payment is sent
+	SkipBalanceCheck bool
+}
+
+// BulkPayResult is the outcome of a single payment in a BulkPay batch
+type BulkPayResult struct {
+	Options     PayDstvOptions
+	Transaction *DStvTransaction
+	Response    *Response
+	Error       error
+}
+
+// BulkPay pays many DStv subscriptions using a bounded pool of workers.
+// The results are returned in the same order as payments. An error is returned without sending any payment when the
+// wallet balance is less than the total price of the batch.
+func (service *BillsService) BulkPay(ctx context.Context, payments []PayDstvOptions, options *BulkPayOptions) ([]*BulkPayResult, error) {
+	if options == nil {
+		options = &BulkPayOptions{}
+	}
+
+	if !options.SkipBalanceCheck {
+		if err := service.checkBulkPayBalance(ctx, payments); err != nil {
+			return nil, err
+		}
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*BulkPayResult, len(payments))
+	for i := range payments {
+		results[i] = &BulkPayResult{Options: payments[i], Error: ErrBulkPaySkipped}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	stopped := make(chan struct{})
+
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if isStopped(ctx, stopped) {
+					continue
+				}
+
+				result := results[i]
+				result.Transaction, result.Response, result.Error = service.PayDStv(ctx, &result.Options)
+				if result.Error != nil && options.StopOnError {
+					once.Do(func() { close(stopped) })
+				}
+			}
+		}()
+	}
+
+	// select picks a random ready case, so the batch is checked before every send to ensure that no payment is
+	// dispatched once it has been stopped.
+dispatch:
+	for i := range payments {
+		if isStopped(ctx, stopped) {
+			break
+		}
+
+		select {
+		case jobs <- i:
+		case <-stopped:
+			break dispatch
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+// isStopped returns true when the batch has been stopped or the context is done
+func isStopped(ctx context.Context, stopped <-chan struct{}) bool {
+	select {
+	case <-stopped:
+		return true
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// checkBulkPayBalance ensures that the wallet balance can cover the total price of the payments
+func (service *BillsService) checkBulkPayBalance(ctx context.Context, payments []PayDstvOptions) error {
+	total := 0.0
+	for i := range payments {
+		price, err := strconv.ParseFloat(payments[i].Price, 64)
+		if err != nil {
+			return fmt.Errorf("mobilenig: invalid price [%s] for payment at index %d: %w", payments[i].Price, i, err)
+		}
+		total += price
+	}
+
+	balance, _, err := service.client.Wallet.GetBalance(ctx)
+	if err != nil {
+		return err
+	}
+
+	amount, err := balance.Amount()
+	if err != nil {
+		return fmt.Errorf("mobilenig: invalid wallet balance [%s]: %w", balance.Balance, err)
+	}
+
+	if amount < total {
+		return &InsufficientBalanceError{Balance: amount, Price: total}
+	}
+
+	return nil
+}
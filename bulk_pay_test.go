@@ -0,0 +1,179 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeBulkPayTestServer creates a server which returns balance for /balance and fails payments for the failingSmartcard
+func makeBulkPayTestServer(balance string, failingSmartcard string, payments *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/balance" {
+			_, _ = res.Write([]byte(`{"balance":"` + balance + `"}`))
+			return
+		}
+
+		atomic.AddInt32(payments, 1)
+		if req.URL.Query().Get("smartno") == failingSmartcard {
+			_, _ = res.Write([]byte(stubs.ErrorResponse()))
+			return
+		}
+		_, _ = res.Write([]byte(`{"trans_id":"` + req.URL.Query().Get("trans_id") + `","details":{"status":"SUCCESSFUL"}}`))
+	}))
+}
+
+func makeBulkPayments(count int) []PayDstvOptions {
+	payments := make([]PayDstvOptions, count)
+	for i := range payments {
+		payments[i] = PayDstvOptions{
+			TransactionID:   "trans-" + string(rune('a'+i)),
+			Price:           "100",
+			ProductCode:     DstvProductCodeCompact,
+			SmartcardNumber: "smartcard-" + string(rune('a'+i)),
+		}
+	}
+	return payments
+}
+
+func TestBillsService_BulkPay_ResultsAreInInputOrder(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var payments int32
+	server := makeBulkPayTestServer("1000", "smartcard-c", &payments)
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL))
+
+	// Act
+	results, err := client.Bills.BulkPay(context.Background(), makeBulkPayments(10), &BulkPayOptions{Concurrency: 3})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, results, 10)
+	assert.Equal(t, int32(10), atomic.LoadInt32(&payments))
+
+	for i, result := range results {
+		assert.Equal(t, "trans-"+string(rune('a'+i)), result.Options.TransactionID)
+		if i == 2 {
+			assert.Error(t, result.Error)
+			assert.Equal(t, "ERR101", result.Response.Error.Code)
+			continue
+		}
+		assert.NoError(t, result.Error)
+		assert.Equal(t, result.Options.TransactionID, result.Transaction.TransactionID)
+	}
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_BulkPay_StopOnError(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var payments int32
+	server := makeBulkPayTestServer("1000", "smartcard-b", &payments)
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL))
+
+	// Act
+	results, err := client.Bills.BulkPay(context.Background(), makeBulkPayments(10), &BulkPayOptions{Concurrency: 1, StopOnError: true})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, results, 10)
+	assert.NoError(t, results[0].Error)
+	assert.Error(t, results[1].Error)
+	assert.True(t, errors.Is(results[9].Error, ErrBulkPaySkipped))
+	assert.Less(t, atomic.LoadInt32(&payments), int32(10))
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_BulkPay_NoPaymentIsSentAfterTheFirstFailure(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var payments int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&payments, 1)
+		if req.URL.Query().Get("smartno") == "smartcard-a" {
+			_, _ = res.Write([]byte(stubs.ErrorResponse()))
+			return
+		}
+
+		// the second worker is busy while the first worker is waiting for a job after the failure
+		time.Sleep(20 * time.Millisecond)
+		_, _ = res.Write([]byte(`{"trans_id":"` + req.URL.Query().Get("trans_id") + `","details":{"status":"SUCCESSFUL"}}`))
+	}))
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL))
+
+	for run := 0; run < 20; run++ {
+		atomic.StoreInt32(&payments, 0)
+
+		// Act
+		results, err := client.Bills.BulkPay(context.Background(), makeBulkPayments(10), &BulkPayOptions{
+			Concurrency:      2,
+			StopOnError:      true,
+			SkipBalanceCheck: true,
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&payments))
+		for _, result := range results[2:] {
+			assert.True(t, errors.Is(result.Error, ErrBulkPaySkipped))
+		}
+	}
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_BulkPay_InsufficientBalance(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var payments int32
+	server := makeBulkPayTestServer("999", "", &payments)
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL))
+
+	// Act
+	results, err := client.Bills.BulkPay(context.Background(), makeBulkPayments(10), nil)
+
+	// Assert
+	assert.True(t, errors.Is(err, ErrInsufficientBalance))
+	assert.Nil(t, results)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&payments))
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_BulkPay_InvalidPrice(t *testing.T) {
+	// Arrange
+	client := New()
+	payments := []PayDstvOptions{{Price: "abc"}}
+
+	// Act
+	_, err := client.Bills.BulkPay(context.Background(), payments, nil)
+
+	// Assert
+	assert.Error(t, err)
+}
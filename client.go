@@ -24,8 +24,38 @@ type Client struct {
 	environment Environment
 	username    string
 	apiKey      string
+	credentials CredentialsProvider
 	baseURL     string
 	Bills       *BillsService
+	Wallet      *WalletService
+
+	deduplicateRequests bool
+	inflight            singleflightGroup
+
+	transactionIDGenerator TransactionIDGenerator
+	transactionIDStore     TransactionIDStore
+
+	ledger LedgerStore
+
+	balanceGuard *BalanceGuard
+	balance      balanceCache
+
+	policy    *policyEngine
+	policyErr error
+
+	retry       *RetryPolicy
+	rateLimiter *rateLimiter
+
+	livePaymentsUnlocked bool
+	testHosts            []string
+
+	dryRun bool
+
+	auditSink    AuditSink
+	onAuditError func(err error)
+
+	strictDecoding  bool
+	onSchemaWarning func(warnings []SchemaWarning)
 }
 
 // New creates and returns a new mobilenig.Client from a slice of mobilenig.ClientOption.
@@ -42,26 +72,98 @@ func New(options ...ClientOption) *Client {
 		username:    config.username,
 		baseURL:     config.baseURL,
 		apiKey:      config.apiKey,
+		credentials: config.credentials,
+
+		deduplicateRequests: config.deduplicateRequests,
+
+		transactionIDGenerator: config.transactionIDGenerator,
+		transactionIDStore:     config.transactionIDStore,
+
+		ledger: config.ledger,
+
+		balanceGuard: config.balanceGuard,
+
+		retry: config.retry,
+
+		livePaymentsUnlocked: config.livePaymentsUnlocked,
+		testHosts:            config.testHosts,
+
+		dryRun: config.dryRun,
+
+		auditSink:    config.auditSink,
+		onAuditError: config.onAuditError,
+
+		strictDecoding:  config.strictDecoding,
+		onSchemaWarning: config.onSchemaWarning,
+	}
+
+	if client.credentials == nil {
+		client.credentials = StaticCredentials(config.username, config.apiKey)
+	}
+
+	// An invalid policy refuses every payment instead of silently disabling rules
+	if config.policy != nil {
+		client.policy, client.policyErr = newPolicyEngine(config.policy)
+	}
+
+	if config.rateLimit > 0 {
+		client.rateLimiter = newRateLimiter(config.rateLimit, config.rateLimitBurst)
 	}
 
 	client.common.client = client
 	client.Bills = (*BillsService)(&client.common)
+	client.Wallet = (*WalletService)(&client.common)
 	return client
 }
 
-// newRequest creates an API request. A relative URL can be provided in uri,
-// in which case it is resolved relative to the apiBaseURL of the Client.
-// URI's should always be specified without a preceding slash.
-func (client *Client) newRequest(ctx context.Context, uri string, params map[string]string) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.baseURL+uri, nil)
+// NewTransactionID generates a new unique transaction ID using the configured TransactionIDGenerator
+func (client *Client) NewTransactionID() (string, error) {
+	return client.transactionIDGenerator.NewTransactionID()
+}
+
+// reserveTransactionID ensures that the transaction ID has not been sent by the client before.
+// It is a no-op when no TransactionIDStore is configured.
+func (client *Client) reserveTransactionID(ctx context.Context, transactionID string) error {
+	if client.transactionIDStore == nil {
+		return nil
+	}
+	return client.transactionIDStore.Reserve(ctx, transactionID)
+}
+
+// releaseTransactionID allows a reserved transaction ID which was never received by MobileNig to be sent again.
+// It is a no-op when no TransactionIDStore is configured.
+func (client *Client) releaseTransactionID(ctx context.Context, transactionID string) {
+	if client.transactionIDStore == nil {
+		return
+	}
+
+	// The transaction ID must be released even when the payment context has been cancelled.
+	_ = client.transactionIDStore.Release(context.Background(), transactionID)
+}
+
+// newRequest creates an API request for the endpoint.
+// The URL is resolved relative to the baseURL of the Client using the environment of the Client.
+func (client *Client) newRequest(ctx context.Context, endpoint endpoint, params map[string]string) (*http.Request, error) {
+	endpointURL, err := client.endpointURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, operationContextKey{}, endpoint.operation)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := client.credentials.Credentials(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	q := req.URL.Query()
 
-	q.Add("username", client.username)
-	q.Add("api_key", client.apiKey)
+	q.Add("username", credentials.Username)
+	q.Add("api_key", credentials.APIKey)
 
 	for key, value := range params {
 		q.Add(key, value)
@@ -72,8 +174,51 @@ func (client *Client) newRequest(ctx context.Context, uri string, params map[str
 	return req, nil
 }
 
-// do carries out an HTTP request and returns a Response
+// do carries out an HTTP request and returns a Response.
+// The request is retried according to the RetryPolicy, so it must not be used for payments.
 func (client *Client) do(req *http.Request) (*Response, error) {
+	resp, err := client.doOnce(req)
+	for attempt := 0; client.retry != nil && attempt < client.retry.MaxRetries && shouldRetry(resp, err); attempt++ {
+		if waitErr := client.retry.wait(req.Context(), attempt); waitErr != nil {
+			return resp, err
+		}
+		resp, err = client.doOnce(req)
+	}
+	return resp, err
+}
+
+// doOnce carries out an HTTP request without retrying it and returns a Response.
+// The request and its response are recorded with the AuditSink.
+func (client *Client) doOnce(req *http.Request) (*Response, error) {
+	if err := client.prepare(req); err != nil {
+		return nil, err
+	}
+	return client.exchange(req)
+}
+
+// prepare waits for the rate limiter and records the request with the AuditSink.
+// The request must not be sent when it returns an error.
+func (client *Client) prepare(req *http.Request) error {
+	if client.rateLimiter != nil {
+		if err := client.rateLimiter.wait(req.Context()); err != nil {
+			return err
+		}
+	}
+
+	return client.auditRequest(req)
+}
+
+// exchange sends the request and records its response with the AuditSink
+func (client *Client) exchange(req *http.Request) (*Response, error) {
+	resp, err := client.roundTrip(req)
+	if auditErr := client.auditResponse(req, resp, err); auditErr != nil && err == nil {
+		return resp, auditErr
+	}
+	return resp, err
+}
+
+// roundTrip carries out an HTTP request and returns a Response
+func (client *Client) roundTrip(req *http.Request) (*Response, error) {
 	httpResponse, err := client.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -113,3 +258,14 @@ func (client *Client) newResponse(httpResponse *http.Response) (*Response, error
 
 	return resp, resp.Err()
 }
+
+// deduplicate executes fn once for concurrent calls which share the same key when request deduplication is enabled.
+// The returned *Response is never shared between callers, the value must be cloned by the caller.
+func (client *Client) deduplicate(ctx context.Context, key string, fn func() (interface{}, *Response, error)) (interface{}, *Response, error) {
+	if !client.deduplicateRequests {
+		return fn()
+	}
+
+	value, resp, _, err := client.inflight.do(ctx, key, fn)
+	return value, resp.clone(), err
+}
@@ -10,6 +10,33 @@ type clientConfig struct {
 	baseURL     string
 	apiKey      string
 	username    string
+	credentials CredentialsProvider
+
+	deduplicateRequests bool
+
+	transactionIDGenerator TransactionIDGenerator
+	transactionIDStore     TransactionIDStore
+
+	ledger LedgerStore
+
+	balanceGuard *BalanceGuard
+
+	policy *PaymentPolicy
+
+	retry          *RetryPolicy
+	rateLimit      float64
+	rateLimitBurst int
+
+	livePaymentsUnlocked bool
+	testHosts            []string
+
+	dryRun bool
+
+	auditSink    AuditSink
+	onAuditError func(err error)
+
+	strictDecoding  bool
+	onSchemaWarning func(warnings []SchemaWarning)
 }
 
 func defaultClientConfig() *clientConfig {
@@ -19,5 +46,9 @@ func defaultClientConfig() *clientConfig {
 		username:    "",
 		baseURL:     apiBaseURL,
 		environment: LiveEnvironment,
+
+		deduplicateRequests: true,
+
+		transactionIDGenerator: NewTransactionIDGenerator(),
 	}
 }
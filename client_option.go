@@ -3,6 +3,7 @@ package mobilenig
 import (
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // ClientOption are options for constructing a client
@@ -50,6 +51,14 @@ func WithAPIKey(apiKey string) ClientOption {
 	})
 }
 
+// WithCredentialsProvider sets the CredentialsProvider which is consulted before every request.
+// It takes precedence over WithUsername and WithAPIKey.
+func WithCredentialsProvider(provider CredentialsProvider) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.credentials = provider
+	})
+}
+
 // WithBaseURL sets the MobileNig API base URL
 func WithBaseURL(baseURL *url.URL) ClientOption {
 	return clientOptionFunc(func(config *clientConfig) {
@@ -58,3 +67,122 @@ func WithBaseURL(baseURL *url.URL) ClientOption {
 		}
 	})
 }
+
+// WithRequestDeduplication configures whether concurrent identical lookups (e.g CheckDStvUser, QueryDStv) share a
+// single HTTP request. By default, deduplication is enabled.
+func WithRequestDeduplication(enabled bool) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.deduplicateRequests = enabled
+	})
+}
+
+// WithTransactionIDGenerator sets the generator used for missing payment transaction IDs.
+// By default, time sortable ULIDs are generated.
+func WithTransactionIDGenerator(generator TransactionIDGenerator) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		if generator != nil {
+			config.transactionIDGenerator = generator
+		}
+	})
+}
+
+// WithTransactionIDGuard refuses payments whose transaction ID has already been sent by the client.
+// A transaction ID is released when its payment is not sent, or when an Outbox finds that MobileNig never received it.
+// The IDs are stored in the TransactionIDStore, an in-memory store is used when the store is nil.
+func WithTransactionIDGuard(store TransactionIDStore) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		if store == nil {
+			store = NewMemoryTransactionIDStore()
+		}
+		config.transactionIDStore = store
+	})
+}
+
+// WithLedger records every payment attempt in the LedgerStore before it is sent and updates it with the outcome.
+func WithLedger(store LedgerStore) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.ledger = store
+	})
+}
+
+// WithBalanceGuard checks the wallet balance before a payment is sent.
+// The payment fails with an *InsufficientBalanceError if the balance minus the price is less than guard.Floor.
+func WithBalanceGuard(guard BalanceGuard) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.balanceGuard = &guard
+	})
+}
+
+// WithPaymentPolicy evaluates the PaymentPolicy before a payment is sent.
+// Payments which break a rule fail with a *PolicyViolation. Every payment fails when the policy is invalid.
+func WithPaymentPolicy(policy *PaymentPolicy) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.policy = policy
+	})
+}
+
+// WithRetryPolicy retries lookups e.g CheckDStvUser when they fail with a network error or a 5xx/429 status code.
+// Payments are never retried.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.retry = &policy
+	})
+}
+
+// WithRateLimit limits the number of requests which are sent per second.
+// The burst is the number of requests which can be sent at once.
+func WithRateLimit(requestsPerSecond float64, burst int) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.rateLimit = requestsPerSecond
+		config.rateLimitBurst = burst
+	})
+}
+
+// WithLivePaymentsUnlocked allows payments to be made in the LiveEnvironment.
+// Without it, payments fail with ErrLivePaymentsLocked unless the base URL is a test host or the
+// MOBILENIG_ALLOW_LIVE_PAYMENTS environment variable is "true". Live payments are always refused inside `go test`.
+func WithLivePaymentsUnlocked() ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.livePaymentsUnlocked = true
+	})
+}
+
+// WithTestHosts allows hosts of the base URL which are not the MobileNig production API e.g. a shared fake server.
+// Payments to test hosts are not locked and operations without a sandbox can be called in the TestEnvironment.
+// Loopback addresses and localhost are always test hosts.
+func WithTestHosts(hosts ...string) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		for _, host := range hosts {
+			config.testHosts = append(config.testHosts, strings.ToLower(strings.TrimSpace(host)))
+		}
+	})
+}
+
+// WithDryRun enables dry-run mode for all payments made by the client.
+// Payments are validated and the request is returned in Response.DryRun with ErrDryRun instead of being sent.
+// Use ContextWithDryRun to enable dry-run mode for a single payment.
+func WithDryRun() ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.dryRun = true
+	})
+}
+
+// WithAuditSink records every request sent by the client and its response with the AuditSink e.g. a FileAuditSink.
+// The API key is redacted. A request which cannot be recorded is not sent and fails with ErrAuditFailed.
+// A failure to record the response is passed to onAuditError, or returned with the response when onAuditError is nil.
+func WithAuditSink(sink AuditSink, onAuditError func(err error)) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.auditSink = sink
+		config.onAuditError = onAuditError
+	})
+}
+
+// WithStrictDecoding compares every MobileNig response with the schema expected by the client.
+// Unexpected and missing fields don't fail the request, they are added to Response.Warnings and passed to
+// onSchemaWarning when it is not nil.
+func WithStrictDecoding(onSchemaWarning func(warnings []SchemaWarning)) ClientOption {
+	return clientOptionFunc(func(config *clientConfig) {
+		config.strictDecoding = true
+		config.onSchemaWarning = onSchemaWarning
+	})
+}
@@ -114,3 +114,45 @@ func TestWithBaseURL(t *testing.T) {
 		assert.Equal(t, apiBaseURL, config.baseURL)
 	})
 }
+
+func TestWithRequestDeduplication(t *testing.T) {
+	t.Run("request deduplication is enabled by default", func(t *testing.T) {
+		// Arrange
+		config := defaultClientConfig()
+
+		// Assert
+		assert.True(t, config.deduplicateRequests)
+	})
+
+	t.Run("request deduplication can be disabled", func(t *testing.T) {
+		// Arrange
+		config := defaultClientConfig()
+
+		// Act
+		WithRequestDeduplication(false).apply(config)
+
+		// Assert
+		assert.False(t, config.deduplicateRequests)
+	})
+}
+
+func TestWithTransactionIDGuard(t *testing.T) {
+	t.Run("no store is configured by default", func(t *testing.T) {
+		// Arrange
+		config := defaultClientConfig()
+
+		// Assert
+		assert.Nil(t, config.transactionIDStore)
+	})
+
+	t.Run("an in-memory store is used when the store is nil", func(t *testing.T) {
+		// Arrange
+		config := defaultClientConfig()
+
+		// Act
+		WithTransactionIDGuard(nil).apply(config)
+
+		// Assert
+		assert.NotNil(t, config.transactionIDStore)
+	})
+}
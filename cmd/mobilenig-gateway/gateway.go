@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// maxBodySize is the maximum size of a request body
+const maxBodySize = 64 * 1024
+
+// paymentTimeout is the maximum duration of a payment. Payments are not cancelled when the client disconnects.
+const paymentTimeout = 2 * time.Minute
+
+// apiError is the body of an error response
+type apiError struct {
+	Code          string `json:"code"`
+	Message       string `json:"message"`
+	Field         string `json:"field,omitempty"`
+	TransactionID string `json:"trans_id,omitempty"`
+}
+
+// validateRequest is the body of POST /dstv/validate
+type validateRequest struct {
+	Smartcard string `json:"smartcard"`
+}
+
+// paymentRequest is the body of POST /dstv/payments
+type paymentRequest struct {
+	TransactionID  string `json:"trans_id"`
+	Smartcard      string `json:"smartcard"`
+	ProductCode    string `json:"product_code"`
+	Price          string `json:"price"`
+	CustomerName   string `json:"customer_name"`
+	CustomerNumber string `json:"customer_number"`
+}
+
+// gateway is the http.Handler of the REST API
+type gateway struct {
+	bills       mobilenig.BillsAPI
+	apiKeys     [][]byte
+	idempotency *idempotencyStore
+	logger      *log.Logger
+	mux         *http.ServeMux
+}
+
+func newGateway(bills mobilenig.BillsAPI, apiKeys []string, logger *log.Logger) *gateway {
+	gateway := &gateway{
+		bills:       bills,
+		idempotency: newIdempotencyStore(idempotencyTTL),
+		logger:      logger,
+		mux:         http.NewServeMux(),
+	}
+
+	for _, key := range apiKeys {
+		gateway.apiKeys = append(gateway.apiKeys, []byte(key))
+	}
+
+	gateway.mux.HandleFunc("/dstv/validate", gateway.validate)
+	gateway.mux.HandleFunc("/dstv/payments", gateway.pay)
+	gateway.mux.HandleFunc("/dstv/payments/", gateway.getPayment)
+	return gateway
+}
+
+// ServeHTTP authenticates the request and routes it
+func (gateway *gateway) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if _, ok := gateway.authenticate(req); !ok {
+		res.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(res, http.StatusUnauthorized, apiError{Code: "unauthorized", Message: "a valid API key is required"})
+		return
+	}
+	gateway.mux.ServeHTTP(res, req)
+}
+
+// authenticate returns an identifier of the API key of the request
+func (gateway *gateway) authenticate(req *http.Request) (string, bool) {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+
+	key := []byte(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+	for _, apiKey := range gateway.apiKeys {
+		if subtle.ConstantTimeCompare(key, apiKey) == 1 {
+			sum := sha256.Sum256(apiKey)
+			return hex.EncodeToString(sum[:8]), true
+		}
+	}
+	return "", false
+}
+
+// validate handles POST /dstv/validate
+func (gateway *gateway) validate(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeMethodNotAllowed(res, http.MethodPost)
+		return
+	}
+
+	body := new(validateRequest)
+	if !decodeBody(res, req, body) {
+		return
+	}
+
+	if body.Smartcard == "" {
+		writeError(res, http.StatusBadRequest, apiError{Code: "invalid_request", Message: "cannot be empty", Field: "smartcard"})
+		return
+	}
+
+	user, resp, err := gateway.bills.CheckDStvUser(req.Context(), body.Smartcard)
+	if err != nil {
+		gateway.writeUpstreamError(res, resp, err, "")
+		return
+	}
+
+	writeJSON(res, http.StatusOK, user)
+}
+
+// pay handles POST /dstv/payments. The response of a request is replayed for requests with the same Idempotency-Key.
+func (gateway *gateway) pay(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeMethodNotAllowed(res, http.MethodPost)
+		return
+	}
+
+	idempotencyKey := strings.TrimSpace(req.Header.Get("Idempotency-Key"))
+	if idempotencyKey == "" || len(idempotencyKey) > 255 {
+		writeError(res, http.StatusBadRequest, apiError{Code: "invalid_request", Message: "the Idempotency-Key header is required and must not be longer than 255 characters"})
+		return
+	}
+
+	body := new(paymentRequest)
+	if !decodeBody(res, req, body) {
+		return
+	}
+
+	owner, _ := gateway.authenticate(req)
+	options := &mobilenig.PayDstvOptions{
+		TransactionID:   body.TransactionID,
+		Price:           body.Price,
+		ProductCode:     mobilenig.DstvProductCode(body.ProductCode),
+		CustomerName:    body.CustomerName,
+		CustomerNumber:  body.CustomerNumber,
+		SmartcardNumber: body.Smartcard,
+	}
+
+	// A retry of the request after a restart must use the same transaction ID, so that the ledger rejects it
+	if options.TransactionID == "" {
+		options.TransactionID = idempotentTransactionID(owner, idempotencyKey)
+	}
+
+	validationErr := new(mobilenig.ValidationError)
+	if err := options.Validate(); errors.As(err, &validationErr) {
+		writeError(res, http.StatusBadRequest, apiError{Code: "invalid_request", Message: validationErr.Message, Field: validationErr.Field})
+		return
+	}
+
+	if !options.ProductCode.IsKnown() {
+		writeError(res, http.StatusBadRequest, apiError{Code: "invalid_request", Message: "unknown product code", Field: "product_code"})
+		return
+	}
+
+	fingerprint, _ := json.Marshal(body)
+	record, state := gateway.idempotency.begin(owner+":"+idempotencyKey, fingerprint)
+	switch state {
+	case idempotencyInProgress:
+		writeError(res, http.StatusConflict, apiError{Code: "request_in_progress", Message: "a request with the same Idempotency-Key is in progress"})
+		return
+	case idempotencyConflict:
+		writeError(res, http.StatusUnprocessableEntity, apiError{Code: "idempotency_key_reused", Message: "the Idempotency-Key was used with a different request"})
+		return
+	case idempotencyCompleted:
+		res.Header().Set("Idempotent-Replayed", "true")
+		writeRaw(res, record.statusCode, record.body)
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: res, statusCode: http.StatusOK}
+	defer func() { gateway.idempotency.complete(record, recorder.statusCode, recorder.body) }()
+
+	// A payment cancelled mid-flight by a disconnect would have an unknown outcome which is replayed for every retry
+	ctx, cancel := context.WithTimeout(context.Background(), paymentTimeout)
+	defer cancel()
+
+	transaction, resp, err := gateway.bills.PayDStv(ctx, options)
+	if err != nil {
+		gateway.writeUpstreamError(recorder, resp, err, options.TransactionID)
+		return
+	}
+
+	gateway.logger.Printf("payment [%s] for smartcard [%s] is %s", options.TransactionID, maskSmartcard(options.SmartcardNumber), transaction.Details.Status)
+	writeJSON(recorder, http.StatusCreated, transaction)
+}
+
+// getPayment handles GET /dstv/payments/{id}
+func (gateway *gateway) getPayment(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeMethodNotAllowed(res, http.MethodGet)
+		return
+	}
+
+	transactionID := strings.TrimPrefix(req.URL.Path, "/dstv/payments/")
+	if transactionID == "" || strings.Contains(transactionID, "/") {
+		writeError(res, http.StatusNotFound, apiError{Code: "not_found", Message: "the payment does not exist"})
+		return
+	}
+
+	transaction, resp, err := gateway.bills.QueryDStv(req.Context(), transactionID)
+	if mobilenig.IsTransactionNotFound(resp, err) {
+		writeError(res, http.StatusNotFound, apiError{Code: "not_found", Message: resp.Error.Description, TransactionID: transactionID})
+		return
+	}
+	if err != nil {
+		gateway.writeUpstreamError(res, resp, err, transactionID)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, transaction)
+}
+
+// writeUpstreamError maps an error returned by the client to an HTTP response
+func (gateway *gateway) writeUpstreamError(res http.ResponseWriter, resp *mobilenig.Response, err error, transactionID string) {
+	validationErr := new(mobilenig.ValidationError)
+	switch {
+	case errors.As(err, &validationErr):
+		writeError(res, http.StatusBadRequest, apiError{Code: "invalid_request", Message: validationErr.Message, Field: validationErr.Field})
+	case errors.Is(err, mobilenig.ErrDuplicateTransactionID):
+		writeError(res, http.StatusConflict, apiError{Code: "duplicate_transaction", Message: err.Error(), TransactionID: transactionID})
+	case errors.Is(err, mobilenig.ErrTransactionAlreadyRecorded):
+		message := "the payment was already sent, fetch the payment to check its status"
+		writeError(res, http.StatusConflict, apiError{Code: "duplicate_transaction", Message: message, TransactionID: transactionID})
+	case errors.Is(err, mobilenig.ErrPolicyViolation):
+		writeError(res, http.StatusUnprocessableEntity, apiError{Code: "policy_violation", Message: err.Error(), TransactionID: transactionID})
+	case errors.Is(err, mobilenig.ErrInsufficientBalance):
+		writeError(res, http.StatusPaymentRequired, apiError{Code: "insufficient_balance", Message: err.Error(), TransactionID: transactionID})
+	case errors.Is(err, mobilenig.ErrLivePaymentsLocked):
+		writeError(res, http.StatusServiceUnavailable, apiError{Code: "live_payments_locked", Message: "live payments are not allowed by the gateway"})
+	case resp != nil && resp.Error != nil:
+		writeError(res, http.StatusBadGateway, apiError{Code: "upstream_error", Message: resp.Error.Description, TransactionID: transactionID})
+	default:
+		gateway.logger.Printf("request for [%s] failed: %s", transactionID, err)
+		message := "MobileNig could not be reached"
+		if transactionID != "" {
+			message = "the outcome of the request is not known, fetch the payment to check its status"
+		}
+		writeError(res, http.StatusBadGateway, apiError{Code: "upstream_unavailable", Message: message, TransactionID: transactionID})
+	}
+}
+
+// idempotentTransactionID derives the transaction ID of a payment without a trans_id from its Idempotency-Key
+func idempotentTransactionID(owner string, idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(owner + ":" + idempotencyKey))
+	return hex.EncodeToString(sum[:13])
+}
+
+// maskSmartcard hides all the digits of a smartcard number except the last 4
+func maskSmartcard(number string) string {
+	if len(number) <= 4 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+// decodeBody decodes the JSON body of the request and writes an error response when it is invalid
+func decodeBody(res http.ResponseWriter, req *http.Request, value interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(res, req.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		writeError(res, http.StatusBadRequest, apiError{Code: "invalid_request", Message: fmt.Sprintf("invalid JSON body: %s", err)})
+		return false
+	}
+	return true
+}
+
+func writeMethodNotAllowed(res http.ResponseWriter, method string) {
+	res.Header().Set("Allow", method)
+	writeError(res, http.StatusMethodNotAllowed, apiError{Code: "method_not_allowed", Message: "use " + method})
+}
+
+func writeError(res http.ResponseWriter, statusCode int, err apiError) {
+	writeJSON(res, statusCode, map[string]apiError{"error": err})
+}
+
+func writeJSON(res http.ResponseWriter, statusCode int, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		statusCode, body = http.StatusInternalServerError, []byte(`{"error":{"code":"internal_error","message":"cannot encode the response"}}`)
+	}
+	writeRaw(res, statusCode, append(body, '\n'))
+}
+
+func writeRaw(res http.ResponseWriter, statusCode int, body []byte) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(statusCode)
+	_, _ = res.Write(body)
+}
+
+// responseRecorder captures the response so it can be replayed for the same Idempotency-Key
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       []byte
+}
+
+func (recorder *responseRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (recorder *responseRecorder) Write(body []byte) (int, error) {
+	recorder.body = append(recorder.body, body...)
+	return recorder.ResponseWriter.Write(body)
+}
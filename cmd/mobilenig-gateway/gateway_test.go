@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go"
+	"github.com/NdoleStudio/mobilenig-go/mobilenigtest"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAPIKey  = "test-api-key"
+	testPayment = `{"trans_id":"trans-1","smartcard":"4131953321","product_code":"COMPE36","price":"1000","customer_name":"John Doe","customer_number":"275953782"}`
+)
+
+func newTestGateway(bills mobilenig.BillsAPI) *gateway {
+	return newGateway(bills, []string{"other-key", testAPIKey}, log.New(ioutil.Discard, "", 0))
+}
+
+func newTestServer() *mobilenigtest.Server {
+	return mobilenigtest.NewServer(
+		mobilenigtest.WithBalance(5000),
+		mobilenigtest.WithSmartcards(mobilenigtest.Smartcard{Number: "4131953321", FirstName: "John", CustomerNumber: 275953782}),
+	)
+}
+
+func serve(handler http.Handler, method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	for key, value := range headers {
+		if value == "" {
+			req.Header.Del(key)
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res
+}
+
+func decodeError(t *testing.T, res *httptest.ResponseRecorder) apiError {
+	var body map[string]apiError
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+	return body["error"]
+}
+
+func TestGateway_RequiresAPIKey(t *testing.T) {
+	// Arrange
+	gateway := newTestGateway(&mobilenigtest.FakeBills{})
+
+	for _, header := range []string{"", "Bearer wrong-key", testAPIKey} {
+		// Act
+		res := serve(gateway, http.MethodPost, "/dstv/validate", `{"smartcard":"4131953321"}`, map[string]string{"Authorization": header})
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+		assert.Equal(t, "unauthorized", decodeError(t, res).Code)
+		assert.Equal(t, "Bearer", res.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestGateway_Validate(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	gateway := newTestGateway(server.Client().Bills)
+
+	// Act
+	res := serve(gateway, http.MethodPost, "/dstv/validate", `{"smartcard":"4131953321"}`, nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, res.Code)
+	user := new(mobilenig.DStvUser)
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), user))
+	assert.Equal(t, "John", user.Details.Firstname)
+
+	// Act
+	res = serve(gateway, http.MethodPost, "/dstv/validate", `{"smartcard":"1111111111"}`, nil)
+
+	// Assert
+	assert.Equal(t, http.StatusBadGateway, res.Code)
+	assert.Equal(t, "upstream_error", decodeError(t, res).Code)
+
+	// Teardown
+	server.Close()
+}
+
+func TestGateway_PayValidatesTheRequest(t *testing.T) {
+	// Arrange
+	fake := &mobilenigtest.FakeBills{PayDStvFunc: mobilenigtest.PayDStvSucceeds()}
+	gateway := newTestGateway(fake)
+
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		field   string
+	}{
+		{name: "missing idempotency key", body: testPayment},
+		{name: "unknown field", body: `{"smartcard":"4131953321","amount":"1000"}`, headers: map[string]string{"Idempotency-Key": "key"}},
+		{name: "invalid JSON", body: `{`, headers: map[string]string{"Idempotency-Key": "key"}},
+		{name: "invalid smartcard", body: strings.Replace(testPayment, "4131953321", "41319a", 1), headers: map[string]string{"Idempotency-Key": "key"}, field: "smartno"},
+		{name: "invalid price", body: strings.Replace(testPayment, `"1000"`, `"-1"`, 1), headers: map[string]string{"Idempotency-Key": "key"}, field: "price"},
+		{name: "unknown product", body: strings.Replace(testPayment, "COMPE36", "UNKNOWN", 1), headers: map[string]string{"Idempotency-Key": "key"}, field: "product_code"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			res := serve(gateway, http.MethodPost, "/dstv/payments", test.body, test.headers)
+
+			// Assert
+			assert.Equal(t, http.StatusBadRequest, res.Code)
+			assert.Equal(t, "invalid_request", decodeError(t, res).Code)
+			assert.Equal(t, test.field, decodeError(t, res).Field)
+		})
+	}
+	assert.Empty(t, fake.CallsTo("PayDStv"))
+}
+
+func TestGateway_PayIsIdempotent(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	gateway := newTestGateway(server.Client().Bills)
+	headers := map[string]string{"Idempotency-Key": "order-1"}
+
+	// Act
+	first := serve(gateway, http.MethodPost, "/dstv/payments", testPayment, headers)
+	second := serve(gateway, http.MethodPost, "/dstv/payments", testPayment, headers)
+	conflict := serve(gateway, http.MethodPost, "/dstv/payments", strings.Replace(testPayment, "trans-1", "trans-2", 1), headers)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, first.Code)
+	transaction := new(mobilenig.DStvTransaction)
+	assert.NoError(t, json.Unmarshal(first.Body.Bytes(), transaction))
+	assert.Equal(t, "trans-1", transaction.TransactionID)
+
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+
+	assert.Equal(t, http.StatusUnprocessableEntity, conflict.Code)
+	assert.Equal(t, "idempotency_key_reused", decodeError(t, conflict).Code)
+
+	assert.Equal(t, 1, server.Calls(mobilenig.OperationPayDStv))
+	assert.Equal(t, float64(4000), server.Balance())
+
+	// Teardown
+	server.Close()
+}
+
+func TestGateway_PayIsNotCancelledWhenTheCallerDisconnects(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	logs := new(bytes.Buffer)
+	gateway := newGateway(server.Client().Bills, []string{testAPIKey}, log.New(logs, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/dstv/payments", strings.NewReader(testPayment)).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	req.Header.Set("Idempotency-Key", "order-1")
+	res := httptest.NewRecorder()
+
+	// Act
+	gateway.ServeHTTP(res, req)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, res.Code)
+	assert.Equal(t, float64(4000), server.Balance())
+	assert.Contains(t, logs.String(), "******3321")
+	assert.NotContains(t, logs.String(), "4131953321")
+
+	// Teardown
+	server.Close()
+}
+
+func TestRun_LivePaymentsRequireALedger(t *testing.T) {
+	// Act
+	err := run(context.Background(), []string{"--allow-live-payments"}, ioutil.Discard)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "--ledger")
+}
+
+func TestGateway_PayIdempotencyKeysAreScopedToTheAPIKey(t *testing.T) {
+	// Arrange
+	fake := &mobilenigtest.FakeBills{PayDStvFunc: mobilenigtest.PayDStvSucceeds()}
+	gateway := newTestGateway(fake)
+	body := strings.Replace(testPayment, `"trans_id":"trans-1",`, "", 1)
+
+	// Act
+	first := serve(gateway, http.MethodPost, "/dstv/payments", body, map[string]string{"Idempotency-Key": "order-1"})
+	second := serve(gateway, http.MethodPost, "/dstv/payments", body, map[string]string{"Idempotency-Key": "order-1", "Authorization": "Bearer other-key"})
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Empty(t, second.Header().Get("Idempotent-Replayed"))
+	assert.Len(t, fake.CallsTo("PayDStv"), 2)
+}
+
+func TestGateway_PayIsNotSentAgainAfterARestart(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	bills := server.Client(mobilenig.WithLedger(mobilenig.NewMemoryLedgerStore())).Bills
+	body := strings.Replace(testPayment, `"trans_id":"trans-1",`, "", 1)
+	headers := map[string]string{"Idempotency-Key": "order-1"}
+
+	first := serve(newTestGateway(bills), http.MethodPost, "/dstv/payments", body, headers)
+
+	// Act
+	retry := serve(newTestGateway(bills), http.MethodPost, "/dstv/payments", body, headers)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, first.Code)
+	transaction := new(mobilenig.DStvTransaction)
+	assert.NoError(t, json.Unmarshal(first.Body.Bytes(), transaction))
+
+	assert.Equal(t, http.StatusConflict, retry.Code)
+	assert.Equal(t, "duplicate_transaction", decodeError(t, retry).Code)
+	assert.Equal(t, transaction.TransactionID, decodeError(t, retry).TransactionID)
+
+	assert.Equal(t, 1, server.Calls(mobilenig.OperationPayDStv))
+
+	// Teardown
+	server.Close()
+}
+
+func TestGateway_PayRequestInProgress(t *testing.T) {
+	// Arrange
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fake := &mobilenigtest.FakeBills{
+		PayDStvFunc: func(ctx context.Context, options *mobilenig.PayDstvOptions) (*mobilenig.DStvTransaction, *mobilenig.Response, error) {
+			close(started)
+			<-release
+			return mobilenigtest.PayDStvSucceeds()(ctx, options)
+		},
+	}
+	gateway := newTestGateway(fake)
+	headers := map[string]string{"Idempotency-Key": "order-1"}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(gateway, http.MethodPost, "/dstv/payments", testPayment, headers)
+	}()
+	<-started
+
+	// Act
+	res := serve(gateway, http.MethodPost, "/dstv/payments", testPayment, headers)
+	close(release)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Equal(t, "request_in_progress", decodeError(t, res).Code)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+}
+
+func TestGateway_PayErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		code       string
+	}{
+		{name: "insufficient balance", err: mobilenig.ErrInsufficientBalance, statusCode: http.StatusPaymentRequired, code: "insufficient_balance"},
+		{name: "policy violation", err: mobilenig.ErrPolicyViolation, statusCode: http.StatusUnprocessableEntity, code: "policy_violation"},
+		{name: "duplicate transaction", err: mobilenig.ErrDuplicateTransactionID, statusCode: http.StatusConflict, code: "duplicate_transaction"},
+		{name: "live payments locked", err: mobilenig.ErrLivePaymentsLocked, statusCode: http.StatusServiceUnavailable, code: "live_payments_locked"},
+		{name: "upstream error", err: context.DeadlineExceeded, statusCode: http.StatusBadGateway, code: "upstream_unavailable"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			err := test.err
+			gateway := newTestGateway(&mobilenigtest.FakeBills{
+				PayDStvFunc: func(ctx context.Context, options *mobilenig.PayDstvOptions) (*mobilenig.DStvTransaction, *mobilenig.Response, error) {
+					return nil, nil, err
+				},
+			})
+
+			// Act
+			res := serve(gateway, http.MethodPost, "/dstv/payments", testPayment, map[string]string{"Idempotency-Key": "order-1"})
+
+			// Assert
+			assert.Equal(t, test.statusCode, res.Code)
+			assert.Equal(t, test.code, decodeError(t, res).Code)
+			if test.code != "live_payments_locked" {
+				assert.Equal(t, "trans-1", decodeError(t, res).TransactionID)
+			}
+		})
+	}
+}
+
+func TestGateway_GetPayment(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	gateway := newTestGateway(server.Client().Bills)
+	serve(gateway, http.MethodPost, "/dstv/payments", testPayment, map[string]string{"Idempotency-Key": "order-1"})
+
+	// Act
+	found := serve(gateway, http.MethodGet, "/dstv/payments/trans-1", "", nil)
+	missing := serve(gateway, http.MethodGet, "/dstv/payments/trans-2", "", nil)
+	wrongMethod := serve(gateway, http.MethodDelete, "/dstv/payments/trans-1", "", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, found.Code)
+	transaction := new(mobilenig.DStvTransaction)
+	assert.NoError(t, json.Unmarshal(found.Body.Bytes(), transaction))
+	assert.Equal(t, "trans-1", transaction.TransactionID)
+
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	assert.Equal(t, "not_found", decodeError(t, missing).Code)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, wrongMethod.Code)
+	assert.Equal(t, http.MethodGet, wrongMethod.Header().Get("Allow"))
+
+	// Teardown
+	server.Close()
+}
+
+func TestGateway_GetPaymentUpstreamError(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	gateway := newTestGateway(server.Client(mobilenig.WithAPIKey("wrong-key")).Bills)
+
+	// Act
+	res := serve(gateway, http.MethodGet, "/dstv/payments/trans-1", "", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusBadGateway, res.Code)
+	assert.Equal(t, "upstream_error", decodeError(t, res).Code)
+	assert.Equal(t, "trans-1", decodeError(t, res).TransactionID)
+
+	// Teardown
+	server.Close()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// idempotencyTTL is how long the response of a request is replayed for the same Idempotency-Key
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyState is the result of starting a request with an Idempotency-Key
+type idempotencyState int
+
+const (
+	// idempotencyStarted means that the key is new and the request must be processed
+	idempotencyStarted idempotencyState = iota
+
+	// idempotencyInProgress means that a request with the same key is being processed
+	idempotencyInProgress
+
+	// idempotencyConflict means that the key was used with a different request
+	idempotencyConflict
+
+	// idempotencyCompleted means that the response of the key must be replayed
+	idempotencyCompleted
+)
+
+// idempotencyRecord is the request and response of an Idempotency-Key
+type idempotencyRecord struct {
+	key         string
+	fingerprint []byte
+	completed   bool
+	statusCode  int
+	body        []byte
+	expiresAt   time.Time
+}
+
+// idempotencyStore keeps the responses of requests in memory
+type idempotencyStore struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	records map[string]*idempotencyRecord
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		now:     time.Now,
+		records: make(map[string]*idempotencyRecord),
+	}
+}
+
+// begin starts a request with the key. The record is returned for idempotencyStarted and idempotencyCompleted.
+func (store *idempotencyStore) begin(key string, fingerprint []byte) (*idempotencyRecord, idempotencyState) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.now()
+	for k, record := range store.records {
+		if record.completed && now.After(record.expiresAt) {
+			delete(store.records, k)
+		}
+	}
+
+	record, ok := store.records[key]
+	switch {
+	case !ok:
+		record = &idempotencyRecord{key: key, fingerprint: fingerprint}
+		store.records[key] = record
+		return record, idempotencyStarted
+	case !bytes.Equal(record.fingerprint, fingerprint):
+		return nil, idempotencyConflict
+	case !record.completed:
+		return nil, idempotencyInProgress
+	default:
+		return &idempotencyRecord{statusCode: record.statusCode, body: append([]byte(nil), record.body...)}, idempotencyCompleted
+	}
+}
+
+// complete stores the response of a request which was started with begin
+func (store *idempotencyStore) complete(record *idempotencyRecord, statusCode int, body []byte) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	record.completed = true
+	record.statusCode = statusCode
+	record.body = append([]byte(nil), body...)
+	record.expiresAt = store.now().Add(store.ttl)
+}
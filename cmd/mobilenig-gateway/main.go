@@ -0,0 +1,164 @@
+// Command mobilenig-gateway exposes the MobileNig client as an internal JSON REST API, so the MobileNig credentials
+// live in a single service.
+//
+// Endpoints:
+//
+//	POST /dstv/validate        validate a DStv smartcard number
+//	POST /dstv/payments        pay a DStv subscription, the Idempotency-Key header is required
+//	GET  /dstv/payments/{id}   fetch a DStv payment
+//
+// Requests are authenticated with an API key in the "Authorization: Bearer <key>" header. The keys are read from a
+// file with one key per line, or from the comma separated MOBILENIG_GATEWAY_API_KEYS environment variable.
+// MobileNig credentials are read from the MOBILENIG_* environment variables or from a JSON config file.
+//
+// Responses of POST /dstv/payments are kept in memory for 24 hours and replayed for requests with the same
+// Idempotency-Key. They are lost when the gateway restarts, so a retry after a restart is not replayed. With --ledger,
+// the retry is rejected with 409 duplicate_transaction because its transaction ID is already in the ledger, and the
+// outcome is fetched with GET /dstv/payments/{id}. The transaction ID of a payment without a trans_id is derived from its
+// Idempotency-Key for this reason. Without --ledger, a retry after a restart is paid again, so --allow-live-payments
+// requires --ledger.
+//
+// Usage:
+//
+//	mobilenig-gateway [--listen :8080] [--config path] [--api-keys path] [--ledger path] [--allow-live-payments]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// envAPIKeys is the environment variable with the comma separated API keys of the gateway
+const envAPIKeys = "MOBILENIG_GATEWAY_API_KEYS"
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil && !errors.Is(err, flag.ErrHelp) {
+		_, _ = fmt.Fprintln(os.Stderr, "mobilenig-gateway:", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the gateway and blocks until ctx is done
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("mobilenig-gateway", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	listen := flags.String("listen", ":8080", "the address of the gateway")
+	configPath := flags.String("config", "", "the JSON config file with the MobileNig credentials, the MOBILENIG_* environment variables are used when empty")
+	apiKeysPath := flags.String("api-keys", "", "the file with the API keys of the gateway, one per line")
+	ledgerPath := flags.String("ledger", "", "the ledger file where payments are recorded")
+	allowLivePayments := flags.Bool("allow-live-payments", false, "allow payments in the live environment")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *allowLivePayments && *ledgerPath == "" {
+		return errors.New("the --allow-live-payments flag requires a --ledger, otherwise payments retried after a restart are paid again")
+	}
+
+	apiKeys, err := loadAPIKeys(*apiKeysPath)
+	if err != nil {
+		return err
+	}
+
+	var options []mobilenig.ClientOption
+	if *ledgerPath != "" {
+		fileStore, err := mobilenig.NewFileLedgerStore(*ledgerPath)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = fileStore.Close() }()
+		options = append(options, mobilenig.WithLedger(fileStore))
+	}
+	if *ledgerPath == "" {
+		_, _ = fmt.Fprintln(stderr, "mobilenig-gateway: warning: without --ledger, payments retried after a restart are paid again")
+	}
+	if *allowLivePayments {
+		options = append(options, mobilenig.WithLivePaymentsUnlocked())
+	}
+
+	client, err := newClient(*configPath, options...)
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(stderr, "mobilenig-gateway: ", log.LstdFlags)
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           newGateway(client.Bills, apiKeys, logger),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	logger.Printf("listening on %s", *listen)
+
+	select {
+	case <-ctx.Done():
+	case err = <-serverErr:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_ = server.Shutdown(shutdownCtx)
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func newClient(configPath string, options ...mobilenig.ClientOption) (*mobilenig.Client, error) {
+	if configPath != "" {
+		return mobilenig.NewFromConfigFile(configPath, options...)
+	}
+	return mobilenig.NewFromEnv(options...)
+}
+
+// loadAPIKeys reads the API keys from the file at path, or from the environment when path is empty
+func loadAPIKeys(path string) ([]string, error) {
+	contents := os.Getenv(envAPIKeys)
+	separator := ","
+	if path != "" {
+		file, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		contents, separator = string(file), "\n"
+	}
+
+	var keys []string
+	for _, key := range strings.Split(contents, separator) {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no API keys are configured, use --api-keys or %s", envAPIKeys)
+	}
+	return keys, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// health is the response of /healthz
+type health struct {
+	Status     string    `json:"status"`
+	LastScanAt time.Time `json:"last_scan_at"`
+	LastError  string    `json:"last_error,omitempty"`
+	Pending    int       `json:"pending"`
+}
+
+// handler serves /healthz and /metrics
+func (reconciler *reconciler) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", reconciler.serveHealth)
+	mux.HandleFunc("/metrics", reconciler.serveMetrics)
+	return mux
+}
+
+// serveHealth is unhealthy when the last scan failed or no scan has completed in the last 3 intervals
+func (reconciler *reconciler) serveHealth(res http.ResponseWriter, _ *http.Request) {
+	reconciler.mu.Lock()
+	response := health{Status: "ok", LastScanAt: reconciler.lastScanAt, Pending: reconciler.metrics.pending}
+	if reconciler.lastError != nil {
+		response.Status, response.LastError = "error", reconciler.lastError.Error()
+	}
+	if reconciler.lastScanAt.IsZero() || reconciler.now().Sub(reconciler.lastScanAt) > 3*reconciler.interval {
+		response.Status = "stale"
+	}
+	reconciler.mu.Unlock()
+
+	status := http.StatusOK
+	if response.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(response)
+}
+
+// serveMetrics writes the metrics in the Prometheus text format
+func (reconciler *reconciler) serveMetrics(res http.ResponseWriter, _ *http.Request) {
+	reconciler.mu.Lock()
+	defer reconciler.mu.Unlock()
+
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	metrics := reconciler.metrics
+	for _, metric := range []struct {
+		name  string
+		kind  string
+		help  string
+		value int
+	}{
+		{name: "mobilenig_reconciler_scans_total", kind: "counter", help: "Number of ledger scans.", value: metrics.scans},
+		{name: "mobilenig_reconciler_scan_errors_total", kind: "counter", help: "Number of ledger scans which failed.", value: metrics.scanErrors},
+		{name: "mobilenig_reconciler_queries_total", kind: "counter", help: "Number of QueryDStv calls.", value: metrics.queries},
+		{name: "mobilenig_reconciler_query_errors_total", kind: "counter", help: "Number of QueryDStv calls which failed.", value: metrics.queryErrors},
+		{name: "mobilenig_reconciler_pending_payments", kind: "gauge", help: "Number of payments whose outcome is not known.", value: metrics.pending},
+	} {
+		_, _ = fmt.Fprintf(res, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value)
+	}
+
+	statuses := make([]mobilenig.LedgerStatus, 0, len(metrics.resolved))
+	for status := range metrics.resolved {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+
+	_, _ = fmt.Fprint(res, "# HELP mobilenig_reconciler_resolved_total Number of payments which reached a terminal status.\n")
+	_, _ = fmt.Fprint(res, "# TYPE mobilenig_reconciler_resolved_total counter\n")
+	for _, status := range statuses {
+		_, _ = fmt.Fprintf(res, "mobilenig_reconciler_resolved_total{status=%q} %d\n", status, metrics.resolved[status])
+	}
+}
@@ -0,0 +1,132 @@
+// Command mobilenig-reconciler resolves the DStv payments in a ledger file whose outcome is not known.
+//
+// It periodically scans the ledger for payments which are pending or unknown and polls QueryDStv for each of them with
+// an exponential backoff. The ledger is updated when a payment reaches a terminal status, and an event is written as a
+// JSON line to the events file. A payment which MobileNig still doesn't know --not-found-after it was sent was never
+// received, so it is marked as FAILED with a payment.not_found event. Health and metrics are served over HTTP on
+// /healthz and /metrics.
+//
+// Credentials are read from the MOBILENIG_* environment variables or from a JSON config file.
+//
+// Usage:
+//
+//	mobilenig-reconciler --ledger <ledger.jsonl> [--config path] [--listen :8080] [--interval 30s] [--not-found-after 24h] [--events path]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil && !errors.Is(err, flag.ErrHelp) {
+		_, _ = fmt.Fprintln(os.Stderr, "mobilenig-reconciler:", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the reconciler and the HTTP server and blocks until ctx is done
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("mobilenig-reconciler", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	ledgerPath := flags.String("ledger", "", "the ledger file of the payments")
+	configPath := flags.String("config", "", "the JSON config file with the credentials, the MOBILENIG_* environment variables are used when empty")
+	listen := flags.String("listen", ":8080", "the address of the health and metrics server")
+	interval := flags.Duration("interval", 30*time.Second, "how often the ledger is scanned")
+	minBackoff := flags.Duration("min-backoff", 10*time.Second, "the delay before a pending payment is polled again")
+	maxBackoff := flags.Duration("max-backoff", 10*time.Minute, "the maximum delay between two polls of a pending payment")
+	notFoundAfter := flags.Duration("not-found-after", 24*time.Hour, "how long after it was sent a payment which MobileNig doesn't know is marked as failed")
+	eventsPath := flags.String("events", "", "the file where events are appended as JSON lines, stdout is used when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *ledgerPath == "" {
+		return errors.New("the --ledger flag is required")
+	}
+	if *interval <= 0 || *minBackoff <= 0 || *maxBackoff < *minBackoff {
+		return errors.New("the interval and backoffs must be positive and --max-backoff cannot be less than --min-backoff")
+	}
+	if *notFoundAfter <= 0 {
+		return errors.New("the --not-found-after flag must be positive")
+	}
+
+	client, err := newClient(*configPath)
+	if err != nil {
+		return err
+	}
+
+	store, err := mobilenig.NewFileLedgerStore(*ledgerPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	events := stdout
+	if *eventsPath != "" {
+		file, err := os.OpenFile(*eventsPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+		events = file
+	}
+
+	reconciler := newReconciler(client.Bills, store, *interval, *minBackoff, *maxBackoff, *notFoundAfter, newEventWriter(events))
+	logger := log.New(stderr, "mobilenig-reconciler: ", log.LstdFlags)
+
+	server := &http.Server{Addr: *listen, Handler: reconciler.handler()}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	logger.Printf("listening on %s", *listen)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reconciler.run(ctx, logger)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-serverErr:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = server.Shutdown(shutdownCtx)
+	<-done
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func newClient(configPath string) (*mobilenig.Client, error) {
+	if configPath != "" {
+		return mobilenig.NewFromConfigFile(configPath)
+	}
+	return mobilenig.NewFromEnv()
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// event is emitted when a payment reaches a terminal status
+type event struct {
+	Type           string                 `json:"type"`
+	TransactionID  string                 `json:"trans_id"`
+	Status         mobilenig.LedgerStatus `json:"status"`
+	PreviousStatus mobilenig.LedgerStatus `json:"previous_status"`
+	RemoteStatus   string                 `json:"remote_status"`
+	Metadata       map[string]string      `json:"metadata,omitempty"`
+	ResolvedAt     time.Time              `json:"resolved_at"`
+}
+
+// newEventWriter returns a function which writes events as JSON lines
+func newEventWriter(writer io.Writer) func(event event) {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(writer)
+	return func(event event) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = encoder.Encode(event)
+	}
+}
+
+// reloader is implemented by stores which can read entries written by other processes e.g. mobilenig.FileLedgerStore
+type reloader interface {
+	Reload(ctx context.Context) error
+}
+
+// backoff is the polling state of a pending payment
+type backoff struct {
+	attempts    int
+	nextAttempt time.Time
+}
+
+// metrics are the counters served on /metrics
+type metrics struct {
+	scans       int
+	scanErrors  int
+	queries     int
+	queryErrors int
+	pending     int
+	resolved    map[mobilenig.LedgerStatus]int
+}
+
+// reconciler polls QueryDStv for the payments in the store whose outcome is not known.
+// A payment which MobileNig still doesn't know notFoundAfter it was created is marked as failed.
+type reconciler struct {
+	bills         mobilenig.BillsAPI
+	store         mobilenig.LedgerStore
+	interval      time.Duration
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	notFoundAfter time.Duration
+	emit          func(event event)
+	now           func() time.Time
+
+	mu         sync.Mutex
+	backoffs   map[string]*backoff
+	metrics    metrics
+	lastScanAt time.Time
+	lastError  error
+}
+
+func newReconciler(bills mobilenig.BillsAPI, store mobilenig.LedgerStore, interval, minBackoff, maxBackoff, notFoundAfter time.Duration, emit func(event event)) *reconciler {
+	return &reconciler{
+		bills:         bills,
+		store:         store,
+		interval:      interval,
+		minBackoff:    minBackoff,
+		maxBackoff:    maxBackoff,
+		notFoundAfter: notFoundAfter,
+		emit:          emit,
+		now:           time.Now,
+		backoffs:      make(map[string]*backoff),
+		metrics:       metrics{resolved: make(map[mobilenig.LedgerStatus]int)},
+	}
+}
+
+// run scans the store every interval until ctx is done
+func (reconciler *reconciler) run(ctx context.Context, logger *log.Logger) {
+	ticker := time.NewTicker(reconciler.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := reconciler.scan(ctx); err != nil && ctx.Err() == nil {
+			logger.Printf("scan failed: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan polls the pending payments which are due and records the outcome of the scan
+func (reconciler *reconciler) scan(ctx context.Context) error {
+	err := reconciler.poll(ctx)
+
+	reconciler.mu.Lock()
+	defer reconciler.mu.Unlock()
+
+	reconciler.metrics.scans++
+	reconciler.lastScanAt = reconciler.now()
+	reconciler.lastError = err
+	if err != nil {
+		reconciler.metrics.scanErrors++
+	}
+	return err
+}
+
+func (reconciler *reconciler) poll(ctx context.Context) error {
+	if store, ok := reconciler.store.(reloader); ok {
+		if err := store.Reload(ctx); err != nil {
+			return err
+		}
+	}
+
+	entries, err := reconciler.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Operation != mobilenig.OperationPayDStv.String() || entry.Status.IsTerminal() || entry.Status == mobilenig.LedgerStatusQueued {
+			continue
+		}
+		pending[entry.TransactionID] = true
+
+		if !reconciler.isDue(entry.TransactionID) {
+			continue
+		}
+
+		if err = reconciler.resolve(ctx, entry); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	reconciler.mu.Lock()
+	defer reconciler.mu.Unlock()
+
+	for transactionID := range reconciler.backoffs {
+		if !pending[transactionID] {
+			delete(reconciler.backoffs, transactionID)
+		}
+	}
+	reconciler.metrics.pending = len(reconciler.backoffs)
+
+	return nil
+}
+
+// resolve queries a payment and updates the store when it has reached a terminal status.
+// Payments which are still pending or cannot be fetched are polled again after a backoff.
+func (reconciler *reconciler) resolve(ctx context.Context, entry *mobilenig.LedgerEntry) error {
+	transaction, resp, err := reconciler.bills.QueryDStv(ctx, entry.TransactionID)
+
+	reconciler.mu.Lock()
+	reconciler.metrics.queries++
+	if err != nil {
+		reconciler.metrics.queryErrors++
+	}
+	reconciler.mu.Unlock()
+
+	eventType, status, remoteStatus, failure := "payment.resolved", mobilenig.LedgerStatusPending, "", ""
+	switch {
+	case err == nil:
+		status, remoteStatus = mobilenig.TransactionLedgerStatus(transaction.Details.Status), transaction.Details.Status
+	case mobilenig.IsTransactionNotFound(resp, err) && reconciler.now().Sub(entry.UpdatedAt) >= reconciler.notFoundAfter:
+		eventType, status = "payment.not_found", mobilenig.LedgerStatusFailed
+		failure = fmt.Sprintf("MobileNig has no transaction [%s] %s after it was sent: %s", entry.TransactionID, reconciler.notFoundAfter, err)
+	}
+
+	if !status.IsTerminal() {
+		reconciler.backOff(entry.TransactionID)
+		return nil
+	}
+
+	previousStatus := entry.Status
+	entry.Status = status
+	entry.Error = failure
+	entry.UpdatedAt = reconciler.now().UTC()
+	if resp != nil && resp.Body != nil && json.Valid(*resp.Body) {
+		entry.Response = append(json.RawMessage(nil), *resp.Body...)
+	}
+
+	if err = reconciler.store.Save(ctx, entry); err != nil {
+		return err
+	}
+
+	reconciler.mu.Lock()
+	delete(reconciler.backoffs, entry.TransactionID)
+	reconciler.metrics.resolved[status]++
+	reconciler.mu.Unlock()
+
+	reconciler.emit(event{
+		Type:           eventType,
+		TransactionID:  entry.TransactionID,
+		Status:         status,
+		PreviousStatus: previousStatus,
+		RemoteStatus:   remoteStatus,
+		Metadata:       entry.Metadata,
+		ResolvedAt:     entry.UpdatedAt,
+	})
+
+	return nil
+}
+
+// isDue returns true when a payment has never been polled or its backoff has expired
+func (reconciler *reconciler) isDue(transactionID string) bool {
+	reconciler.mu.Lock()
+	defer reconciler.mu.Unlock()
+
+	state, ok := reconciler.backoffs[transactionID]
+	return !ok || !reconciler.now().Before(state.nextAttempt)
+}
+
+// backOff doubles the delay before a payment is polled again, up to maxBackoff
+func (reconciler *reconciler) backOff(transactionID string) {
+	reconciler.mu.Lock()
+	defer reconciler.mu.Unlock()
+
+	state, ok := reconciler.backoffs[transactionID]
+	if !ok {
+		state = new(backoff)
+		reconciler.backoffs[transactionID] = state
+	}
+
+	delay := reconciler.minBackoff
+	for i := 0; i < state.attempts && delay < reconciler.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > reconciler.maxBackoff {
+		delay = reconciler.maxBackoff
+	}
+
+	state.attempts++
+	state.nextAttempt = reconciler.now().Add(delay)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+	"github.com/NdoleStudio/mobilenig-go/mobilenigtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLedgerEntry(transactionID string, status mobilenig.LedgerStatus) *mobilenig.LedgerEntry {
+	return &mobilenig.LedgerEntry{
+		TransactionID: transactionID,
+		Operation:     mobilenig.OperationPayDStv.String(),
+		Status:        status,
+		Request:       map[string]string{"trans_id": transactionID, "price": "1000"},
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+}
+
+func TestReconciler_Scan(t *testing.T) {
+	// Arrange
+	server := mobilenigtest.NewServer(
+		mobilenigtest.WithBalance(5000),
+		mobilenigtest.WithSmartcards(mobilenigtest.Smartcard{Number: "4131953321", CustomerNumber: 275953782}),
+	)
+	client := server.Client()
+	for _, transactionID := range []string{"succeeded", "failed", "pending"} {
+		_, _, err := client.Bills.PayDStv(context.Background(), &mobilenig.PayDstvOptions{
+			TransactionID:   transactionID,
+			Price:           "1000",
+			SmartcardNumber: "4131953321",
+		})
+		assert.NoError(t, err)
+	}
+	server.SetPaymentStatus("failed", "FAILED")
+	server.SetPaymentStatus("pending", "PENDING")
+
+	store := mobilenig.NewMemoryLedgerStore()
+	for _, entry := range []*mobilenig.LedgerEntry{
+		newTestLedgerEntry("succeeded", mobilenig.LedgerStatusUnknown),
+		newTestLedgerEntry("failed", mobilenig.LedgerStatusPending),
+		newTestLedgerEntry("pending", mobilenig.LedgerStatusPending),
+		newTestLedgerEntry("queued", mobilenig.LedgerStatusQueued),
+	} {
+		assert.NoError(t, store.Save(context.Background(), entry))
+	}
+
+	var events []event
+	reconciler := newReconciler(client.Bills, store, time.Minute, time.Minute, time.Hour, 24*time.Hour, func(event event) {
+		events = append(events, event)
+	})
+
+	// Act
+	err := reconciler.scan(context.Background())
+	assert.NoError(t, err)
+	err = reconciler.scan(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+
+	succeeded, _ := store.Get(context.Background(), "succeeded")
+	assert.Equal(t, mobilenig.LedgerStatusSucceeded, succeeded.Status)
+	failed, _ := store.Get(context.Background(), "failed")
+	assert.Equal(t, mobilenig.LedgerStatusFailed, failed.Status)
+	pending, _ := store.Get(context.Background(), "pending")
+	assert.Equal(t, mobilenig.LedgerStatusPending, pending.Status)
+
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, mobilenig.LedgerStatusUnknown, events[0].PreviousStatus)
+	assert.Equal(t, mobilenig.LedgerStatusSucceeded, events[0].Status)
+
+	// the pending payment is not polled again before its backoff expires
+	assert.Equal(t, 3, server.Calls(mobilenig.OperationQueryDStv))
+	assert.Equal(t, 1, reconciler.metrics.pending)
+
+	// Teardown
+	server.Close()
+}
+
+func TestReconciler_Scan_NotFound(t *testing.T) {
+	// Arrange
+	server := mobilenigtest.NewServer()
+
+	old := newTestLedgerEntry("old", mobilenig.LedgerStatusUnknown)
+	old.CreatedAt, old.UpdatedAt = time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)
+
+	// A payment which was queued a long time ago but sent recently
+	recent := newTestLedgerEntry("recent", mobilenig.LedgerStatusUnknown)
+	recent.CreatedAt = time.Now().Add(-48 * time.Hour)
+
+	store := mobilenig.NewMemoryLedgerStore()
+	for _, entry := range []*mobilenig.LedgerEntry{old, recent} {
+		assert.NoError(t, store.Save(context.Background(), entry))
+	}
+
+	var events []event
+	reconciler := newReconciler(server.Client().Bills, store, time.Minute, time.Minute, time.Hour, 24*time.Hour, func(event event) {
+		events = append(events, event)
+	})
+
+	// Act
+	err := reconciler.scan(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+
+	entry, _ := store.Get(context.Background(), "old")
+	assert.Equal(t, mobilenig.LedgerStatusFailed, entry.Status)
+	assert.Contains(t, entry.Error, "MobileNig has no transaction [old]")
+
+	entry, _ = store.Get(context.Background(), "recent")
+	assert.Equal(t, mobilenig.LedgerStatusUnknown, entry.Status)
+
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "payment.not_found", events[0].Type)
+	assert.Equal(t, "old", events[0].TransactionID)
+	assert.Equal(t, 1, reconciler.metrics.pending)
+
+	// Teardown
+	server.Close()
+}
+
+func TestReconciler_Scan_NotFoundOnlyOnTheNotFoundError(t *testing.T) {
+	// Arrange
+	server := mobilenigtest.NewServer()
+
+	old := newTestLedgerEntry("old", mobilenig.LedgerStatusUnknown)
+	old.CreatedAt, old.UpdatedAt = time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)
+
+	store := mobilenig.NewMemoryLedgerStore()
+	assert.NoError(t, store.Save(context.Background(), old))
+
+	client := server.Client(mobilenig.WithAPIKey("wrong-key"))
+	reconciler := newReconciler(client.Bills, store, time.Minute, time.Minute, time.Hour, 24*time.Hour, func(event) {})
+
+	// Act
+	err := reconciler.scan(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	entry, _ := store.Get(context.Background(), "old")
+	assert.Equal(t, mobilenig.LedgerStatusUnknown, entry.Status)
+
+	// Teardown
+	server.Close()
+}
+
+func TestReconciler_Handler(t *testing.T) {
+	// Arrange
+	reconciler := newReconciler(&mobilenigtest.FakeBills{}, mobilenig.NewMemoryLedgerStore(), time.Minute, time.Minute, time.Hour, 24*time.Hour, func(event) {})
+	server := httptest.NewServer(reconciler.handler())
+
+	t.Run("it is unhealthy before the first scan", func(t *testing.T) {
+		// Act
+		resp, err := http.Get(server.URL + "/healthz")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		_ = resp.Body.Close()
+	})
+
+	t.Run("it is healthy and serves metrics after a scan", func(t *testing.T) {
+		// Arrange
+		assert.NoError(t, reconciler.scan(context.Background()))
+
+		// Act
+		healthResp, err := http.Get(server.URL + "/healthz")
+		assert.NoError(t, err)
+		metricsResp, metricsErr := http.Get(server.URL + "/metrics")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, healthResp.StatusCode)
+		assert.NoError(t, metricsErr)
+
+		body, _ := ioutil.ReadAll(metricsResp.Body)
+		assert.Contains(t, string(body), "mobilenig_reconciler_scans_total 1\n")
+
+		_ = healthResp.Body.Close()
+		_ = metricsResp.Body.Close()
+	})
+
+	// Teardown
+	server.Close()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func (cli *cli) balance(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: balance has no arguments", errUsage)
+	}
+
+	client, err := cli.newClient()
+	if err != nil {
+		return err
+	}
+
+	balance, _, err := client.Wallet.GetBalance(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return cli.print(balance, row{"Balance", balance.Balance})
+}
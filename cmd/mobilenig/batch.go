@@ -0,0 +1,564 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// batchColumns are the columns of the CSV file of a batch. The trans_id column is optional.
+var batchColumns = []string{"smartcard", "product_code", "price", "customer_name", "customer_number"}
+
+// batchStatusNotStarted is the status of a row which was never enqueued
+const batchStatusNotStarted = "NOT_STARTED"
+
+// batchRow is a payment in the CSV file of a batch
+type batchRow struct {
+	number  int
+	options mobilenig.PayDstvOptions
+	entry   *mobilenig.LedgerEntry
+	errors  []string
+}
+
+func (row *batchRow) isDone() bool {
+	return row.entry != nil && row.entry.Status.IsTerminal()
+}
+
+func (row *batchRow) status() string {
+	if row.entry == nil {
+		return batchStatusNotStarted
+	}
+	return row.entry.Status.String()
+}
+
+// batch runs the batch subcommands
+func (cli *cli) batch(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "pay":
+		return cli.batchPay(args[1:])
+	default:
+		return fmt.Errorf("%w: unknown batch command [%s]", errUsage, args[0])
+	}
+}
+
+// batchPay validates every row of a CSV file and then pays them with bounded concurrency.
+// The progress is stored in a ledger file so an interrupted run can be resumed without paying a row twice.
+func (cli *cli) batchPay(args []string) error {
+	var path, statePath, resultsPath string
+	var concurrency int
+	var yes, confirm bool
+
+	flags := flag.NewFlagSet("mobilenig batch pay", flag.ContinueOnError)
+	flags.SetOutput(cli.stderr)
+	flags.StringVar(&path, "file", "", "the CSV file with the columns "+strings.Join(batchColumns, ",")+" and an optional trans_id")
+	flags.StringVar(&statePath, "state", "", "the file which stores the progress, defaults to <file>.state.jsonl")
+	flags.StringVar(&resultsPath, "results", "", "the CSV file with the results, defaults to <file>.results.csv")
+	flags.IntVar(&concurrency, "concurrency", 4, "the number of payments which are sent at the same time")
+	flags.BoolVar(&yes, "yes", false, "pay without asking for confirmation")
+	flags.BoolVar(&confirm, "confirm", false, "confirm that the payments can be made in the live environment")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if path == "" {
+		return fmt.Errorf("%w: batch pay needs a --file", errUsage)
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("%w: the concurrency must be at least 1", errUsage)
+	}
+	if statePath == "" {
+		statePath = path + ".state.jsonl"
+	}
+	if resultsPath == "" {
+		resultsPath = strings.TrimSuffix(path, filepath.Ext(path)) + ".results.csv"
+	}
+
+	rows, err := readBatchFile(path)
+	if err != nil {
+		return err
+	}
+
+	store, err := mobilenig.NewFileLedgerStore(statePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	clientOptions := []mobilenig.ClientOption{mobilenig.WithLedger(store)}
+	if confirm {
+		clientOptions = append(clientOptions, mobilenig.WithLivePaymentsUnlocked())
+	}
+
+	client, err := cli.newClient(clientOptions...)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if err = restoreBatchState(ctx, client, store, rows); err != nil {
+		return err
+	}
+
+	if err = validateBatch(ctx, client, rows, concurrency); err != nil {
+		for _, row := range rows {
+			for _, message := range row.errors {
+				_, _ = fmt.Fprintf(cli.stderr, "row %d: %s\n", row.number, message)
+			}
+		}
+		return err
+	}
+
+	proceed, err := cli.confirmBatch(ctx, client, rows, yes)
+	if err != nil || !proceed {
+		return err
+	}
+
+	runErr := executeBatch(ctx, client, store, rows, concurrency)
+
+	if err = refreshBatchState(ctx, store, rows); err != nil {
+		return err
+	}
+	if err = writeBatchResults(resultsPath, rows); err != nil {
+		return err
+	}
+	if runErr != nil {
+		for _, row := range rows {
+			for _, message := range row.errors {
+				_, _ = fmt.Fprintf(cli.stderr, "row %d: %s\n", row.number, message)
+			}
+		}
+		return fmt.Errorf("%w, run the command again to resume", runErr)
+	}
+
+	return cli.printBatchSummary(rows, resultsPath)
+}
+
+// readBatchFile reads the rows of the CSV file of a batch
+func readBatchFile(path string) ([]*batchRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the header of [%s]: %w", path, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range batchColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the file [%s] has no [%s] column", path, name)
+		}
+	}
+
+	value := func(record []string, name string) string {
+		if index, ok := columns[name]; ok && index < len(record) {
+			return strings.TrimSpace(record[index])
+		}
+		return ""
+	}
+
+	var rows []*batchRow
+	transactionRows := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read [%s]: %w", path, err)
+		}
+
+		number := len(rows) + 1
+		if transactionID := value(record, "trans_id"); transactionID != "" {
+			if previous, ok := transactionRows[transactionID]; ok {
+				return nil, fmt.Errorf("rows %d and %d of [%s] have the same trans_id [%s]", previous, number, path, transactionID)
+			}
+			transactionRows[transactionID] = number
+		}
+
+		rows = append(rows, &batchRow{
+			number: number,
+			options: mobilenig.PayDstvOptions{
+				TransactionID:   value(record, "trans_id"),
+				Price:           value(record, "price"),
+				ProductCode:     mobilenig.DstvProductCode(value(record, "product_code")),
+				CustomerName:    value(record, "customer_name"),
+				CustomerNumber:  value(record, "customer_number"),
+				SmartcardNumber: value(record, "smartcard"),
+			},
+		})
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("the file [%s] has no payments", path)
+	}
+
+	return rows, nil
+}
+
+// restoreBatchState matches the rows with the entries of a previous run and generates the missing transaction IDs
+func restoreBatchState(ctx context.Context, client *mobilenig.Client, store mobilenig.LedgerStore, rows []*batchRow) error {
+	entries, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		number, err := strconv.Atoi(entry.Metadata["row"])
+		if err != nil || number < 1 || number > len(rows) {
+			return fmt.Errorf("the state file contains the transaction [%s] which is not in the CSV file", entry.TransactionID)
+		}
+
+		row := rows[number-1]
+		if (row.options.TransactionID != "" && row.options.TransactionID != entry.TransactionID) ||
+			entry.Request["smartno"] != row.options.SmartcardNumber ||
+			entry.Request["price"] != row.options.Price ||
+			entry.Request["product_code"] != string(row.options.ProductCode) {
+			return fmt.Errorf("row %d does not match the transaction [%s] in the state file", number, entry.TransactionID)
+		}
+
+		row.entry = entry
+		row.options.TransactionID = entry.TransactionID
+	}
+
+	for _, row := range rows {
+		if row.options.TransactionID == "" {
+			if row.options.TransactionID, err = client.NewTransactionID(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// refreshBatchState reads the latest entries of the rows from the store
+func refreshBatchState(ctx context.Context, store mobilenig.LedgerStore, rows []*batchRow) error {
+	for _, row := range rows {
+		entry, err := store.Get(ctx, row.options.TransactionID)
+		if errors.Is(err, mobilenig.ErrLedgerEntryNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		row.entry = entry
+	}
+	return nil
+}
+
+// validateBatch checks the rows which have not been paid. Every smartcard is validated with CheckDStvUser.
+func validateBatch(ctx context.Context, client *mobilenig.Client, rows []*batchRow, concurrency int) error {
+	var smartcards []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if row.isDone() {
+			continue
+		}
+
+		if err := row.options.Validate(); err != nil {
+			row.errors = append(row.errors, err.Error())
+		}
+		if !row.options.ProductCode.IsKnown() {
+			row.errors = append(row.errors, fmt.Sprintf("unknown product code [%s]", row.options.ProductCode))
+		}
+
+		if smartcard := row.options.SmartcardNumber; smartcard != "" && !seen[smartcard] {
+			seen[smartcard] = true
+			smartcards = append(smartcards, smartcard)
+		}
+	}
+
+	type smartcardResult struct {
+		smartcard string
+		err       error
+	}
+
+	jobs := make(chan string)
+	results := make(chan smartcardResult)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for smartcard := range jobs {
+				_, _, err := client.Bills.CheckDStvUser(ctx, smartcard)
+				results <- smartcardResult{smartcard: smartcard, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, smartcard := range smartcards {
+			jobs <- smartcard
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	smartcardErrors := make(map[string]error)
+	for result := range results {
+		smartcardErrors[result.smartcard] = result.err
+	}
+
+	invalid := 0
+	for _, row := range rows {
+		if err := smartcardErrors[row.options.SmartcardNumber]; err != nil && !row.isDone() {
+			row.errors = append(row.errors, fmt.Sprintf("invalid smartcard [%s]: %s", row.options.SmartcardNumber, err))
+		}
+		if len(row.errors) > 0 {
+			invalid++
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d rows are invalid, no payment was made", invalid, len(rows))
+	}
+	return ctx.Err()
+}
+
+// confirmBatch prints a summary of the batch and asks for confirmation unless yes is true
+func (cli *cli) confirmBatch(ctx context.Context, client *mobilenig.Client, rows []*batchRow, yes bool) (bool, error) {
+	var pending int
+	var total float64
+	for _, row := range rows {
+		if !row.isDone() {
+			pending++
+			price, _ := strconv.ParseFloat(row.options.Price, 64)
+			total += price
+		}
+	}
+
+	balance, _, err := client.Wallet.GetBalance(ctx)
+	if err != nil {
+		return false, err
+	}
+	amount, err := balance.Amount()
+	if err != nil {
+		return false, fmt.Errorf("invalid wallet balance [%s]: %w", balance.Balance, err)
+	}
+
+	err = cli.print(map[string]interface{}{"rows": len(rows), "done": len(rows) - pending, "pending": pending, "total": total, "balance": amount},
+		row{"Rows", len(rows)},
+		row{"Already done", len(rows) - pending},
+		row{"To pay", pending},
+		row{"Total", strconv.FormatFloat(total, 'f', 2, 64)},
+		row{"Wallet balance", strconv.FormatFloat(amount, 'f', 2, 64)},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if pending == 0 {
+		return false, nil
+	}
+	if amount < total {
+		return false, fmt.Errorf("the wallet balance %.2f is less than the total %.2f, no payment was made", amount, total)
+	}
+	if yes {
+		return true, nil
+	}
+
+	_, _ = fmt.Fprintf(cli.stderr, "Pay %d subscriptions for %.2f? [y/N] ", pending, total)
+	answer, err := bufio.NewReader(cli.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		_, _ = fmt.Fprintln(cli.stderr, "No payment was made")
+		return false, nil
+	}
+	return true, nil
+}
+
+// executeBatch enqueues the new rows, resolves the payments which were in flight when a previous run stopped and pays
+// the queued rows. Payments which cannot be resolved are never sent again.
+// The run stops when a payment is refused before it is sent e.g. by the live payment lock, and the error is reported on
+// the row which stays queued.
+func executeBatch(ctx context.Context, client *mobilenig.Client, store mobilenig.LedgerStore, rows []*batchRow, concurrency int) error {
+	outbox := mobilenig.NewOutbox(client, store)
+	for _, row := range rows {
+		if row.entry != nil {
+			continue
+		}
+
+		options := row.options
+		entry, err := outbox.EnqueuePayDStv(ctx, &options, map[string]string{"row": strconv.Itoa(row.number)})
+		if err != nil {
+			return err
+		}
+		row.entry = entry
+	}
+
+	if err := outbox.Recover(ctx); err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	if err := refreshBatchState(ctx, store, rows); err != nil {
+		return err
+	}
+
+	jobs := make(chan *batchRow)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var runErr error
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range jobs {
+				select {
+				case <-stop:
+					continue
+				default:
+				}
+
+				options := row.options
+				_, _, err := client.Bills.PayDStv(ctx, &options)
+				if err == nil || !errors.Is(err, mobilenig.ErrPaymentNotSent) || ctx.Err() != nil {
+					continue
+				}
+
+				stopOnce.Do(func() {
+					runErr = fmt.Errorf("row %d was not paid: %w", row.number, err)
+					close(stop)
+				})
+				row.errors = append(row.errors, err.Error())
+			}
+		}()
+	}
+
+dispatch:
+	for _, row := range rows {
+		if row.entry.Status != mobilenig.LedgerStatusQueued {
+			continue
+		}
+
+		select {
+		case jobs <- row:
+		case <-stop:
+			break dispatch
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if runErr != nil {
+		return runErr
+	}
+	return ctx.Err()
+}
+
+// writeBatchResults writes the status of every row to a CSV file
+func writeBatchResults(path string, rows []*batchRow) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	_ = writer.Write([]string{"row", "trans_id", "smartcard", "product_code", "price", "customer_name", "customer_number", "status", "error"})
+	for _, row := range rows {
+		message := strings.Join(row.errors, "; ")
+		if row.entry != nil && row.entry.Error != "" {
+			message = row.entry.Error
+		}
+
+		_ = writer.Write([]string{
+			strconv.Itoa(row.number),
+			row.options.TransactionID,
+			row.options.SmartcardNumber,
+			string(row.options.ProductCode),
+			row.options.Price,
+			row.options.CustomerName,
+			row.options.CustomerNumber,
+			row.status(),
+			message,
+		})
+	}
+	writer.Flush()
+
+	if err = writer.Error(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// printBatchSummary prints the number of rows with each status
+func (cli *cli) printBatchSummary(rows []*batchRow, resultsPath string) error {
+	counts := make(map[string]int)
+	for _, row := range rows {
+		counts[row.status()]++
+	}
+
+	err := cli.print(map[string]interface{}{"statuses": counts, "results": resultsPath},
+		row{"Succeeded", counts[mobilenig.LedgerStatusSucceeded.String()]},
+		row{"Failed", counts[mobilenig.LedgerStatusFailed.String()]},
+		row{"Unresolved", len(rows) - counts[mobilenig.LedgerStatusSucceeded.String()] - counts[mobilenig.LedgerStatusFailed.String()]},
+		row{"Results", resultsPath},
+	)
+	if err != nil {
+		return err
+	}
+
+	if counts[mobilenig.LedgerStatusSucceeded.String()] != len(rows) {
+		return fmt.Errorf("%d of %d payments did not succeed, see [%s]", len(rows)-counts[mobilenig.LedgerStatusSucceeded.String()], len(rows), resultsPath)
+	}
+	return nil
+}
+
+// interruptContext returns a context which is cancelled when the process receives SIGINT or SIGTERM
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go"
+	"github.com/NdoleStudio/mobilenig-go/mobilenigtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestBatchFile writes a CSV file with the rows and returns its path
+func newTestBatchFile(t *testing.T, rows ...string) string {
+	path := filepath.Join(t.TempDir(), "payments.csv")
+	contents := "smartcard,product_code,price,customer_name,customer_number\n" + strings.Join(rows, "\n") + "\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestRun_BatchPay(t *testing.T) {
+	t.Run("invalid rows are reported and nothing is paid", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		config := newTestConfig(t, server)
+		file := newTestBatchFile(t,
+			"4131953321,COMPE36,2000,ESU,275953782",
+			"0000000000,COMPE36,2000,ESU,275953782",
+			"4131953321,UNKNOWN,-1,ESU,275953782",
+		)
+
+		// Act
+		code, _, stderr := runTest("--config", config, "batch", "pay", "--file", file, "--yes")
+
+		// Assert
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "row 2: invalid smartcard [0000000000]")
+		assert.Contains(t, stderr, "row 3: mobilenig: invalid [price]")
+		assert.Contains(t, stderr, "row 3: unknown product code [UNKNOWN]")
+		assert.Empty(t, server.Payments())
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("an interrupted batch is resumed without paying twice", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		config := newTestConfig(t, server)
+		file := newTestBatchFile(t,
+			"4131953321,COMPE36,1000,ESU,275953782",
+			"4131953321,NNJ1E36,500,ESU,275953782",
+			"4131953321,NLTESE36,300,ESU,275953782",
+		)
+		server.InjectFailure(mobilenig.OperationPayDStv, mobilenigtest.Failure{
+			StatusCode:      http.StatusBadGateway,
+			Body:            "<html>Bad Gateway</html>",
+			AfterProcessing: true,
+		}, 1)
+
+		// Act
+		firstCode, _, _ := runTest("--config", config, "batch", "pay", "--file", file, "--yes", "--concurrency", "2")
+		secondCode, stdout, stderr := runTest("--config", config, "batch", "pay", "--file", file, "--yes")
+
+		// Assert
+		assert.Equal(t, 1, firstCode)
+		assert.Equal(t, 0, secondCode, stderr)
+		assert.Contains(t, stdout, "Succeeded   3")
+
+		assert.Equal(t, 3, len(server.Payments()))
+		assert.Equal(t, float64(3200), server.Balance())
+
+		results, err := ioutil.ReadFile(strings.TrimSuffix(file, ".csv") + ".results.csv")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, strings.Count(string(results), ",SUCCEEDED,"))
+
+		// Teardown
+		server.Close()
+	})
+	t.Run("duplicate transaction IDs are rejected before anything is enqueued", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		config := newTestConfig(t, server)
+		file := filepath.Join(t.TempDir(), "payments.csv")
+		contents := "trans_id,smartcard,product_code,price,customer_name,customer_number\n" +
+			"1001,4131953321,COMPE36,1000,ESU,275953782\n" +
+			"1002,4131953321,NNJ1E36,500,ESU,275953782\n" +
+			"1001,4131953321,NLTESE36,300,ESU,275953782\n"
+		assert.NoError(t, ioutil.WriteFile(file, []byte(contents), 0o600))
+
+		// Act
+		code, _, stderr := runTest("--config", config, "batch", "pay", "--file", file, "--yes")
+
+		// Assert
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "rows 1 and 3")
+		assert.Empty(t, server.Payments())
+
+		_, err := ioutil.ReadFile(file + ".state.jsonl")
+		assert.True(t, os.IsNotExist(err))
+
+		// Teardown
+		server.Close()
+	})
+}
+
+func TestExecuteBatch_StopsWhenAPaymentIsNotSent(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	store := mobilenig.NewMemoryLedgerStore()
+	client := server.Client(mobilenig.WithLedger(store), mobilenig.WithPaymentPolicy(&mobilenig.PaymentPolicy{MaxAmount: 1000}))
+	rows := []*batchRow{
+		{number: 1, options: mobilenig.PayDstvOptions{TransactionID: "1001", SmartcardNumber: "4131953321", ProductCode: mobilenig.DstvProductCodePremium, Price: "2000"}},
+		{number: 2, options: mobilenig.PayDstvOptions{TransactionID: "1002", SmartcardNumber: "4131953321", ProductCode: mobilenig.DstvProductCodeCompact, Price: "500"}},
+	}
+
+	// Act
+	err := executeBatch(context.Background(), client, store, rows, 1)
+
+	// Assert
+	assert.True(t, errors.Is(err, mobilenig.ErrPolicyViolation))
+	assert.Contains(t, err.Error(), "row 1")
+	assert.Len(t, rows[0].errors, 1)
+	assert.Empty(t, server.Payments())
+
+	for _, transactionID := range []string{"1001", "1002"} {
+		entry, getErr := store.Get(context.Background(), transactionID)
+		assert.NoError(t, getErr)
+		assert.Equal(t, mobilenig.LedgerStatusQueued, entry.Status)
+	}
+
+	// Teardown
+	server.Close()
+}
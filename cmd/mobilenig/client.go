@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// envProfile is the environment variable which contains the default profile
+const envProfile = "MOBILENIG_PROFILE"
+
+// newClient creates a client using the config file, the profile or the environment variables in that order
+func (cli *cli) newClient(options ...mobilenig.ClientOption) (*mobilenig.Client, error) {
+	if cli.configPath != "" {
+		return mobilenig.NewFromConfigFile(cli.configPath, options...)
+	}
+
+	if cli.profile != "" {
+		path, err := profilePath(cli.profile)
+		if err != nil {
+			return nil, err
+		}
+		return mobilenig.NewFromConfigFile(path, options...)
+	}
+
+	return mobilenig.NewFromEnv(options...)
+}
+
+// profilePath returns the path of the config file of a profile
+func profilePath(profile string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mobilenig", profile+".json"), nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// dstv runs the dstv subcommands
+func (cli *cli) dstv(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "check":
+		return cli.dstvCheck(args[1:])
+	case "package":
+		return cli.dstvPackage(args[1:])
+	case "pay":
+		return cli.dstvPay(args[1:])
+	case "query":
+		return cli.dstvQuery(args[1:])
+	default:
+		return fmt.Errorf("%w: unknown dstv command [%s]", errUsage, args[0])
+	}
+}
+
+func (cli *cli) dstvCheck(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: dstv check needs a smartcard number", errUsage)
+	}
+
+	client, err := cli.newClient()
+	if err != nil {
+		return err
+	}
+
+	user, _, err := client.Bills.CheckDStvUser(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	return cli.print(user,
+		row{"Smartcard", args[0]},
+		row{"First name", user.Details.Firstname},
+		row{"Last name", user.Details.Lastname},
+		row{"Account status", user.Details.AccountStatus},
+		row{"Customer type", user.Details.CustomerType},
+		row{"Customer number", user.Details.CustomerNumber},
+		row{"Invoice period", user.Details.InvoicePeriod},
+		row{"Due date", user.Details.DueDate.Format("2006-01-02")},
+	)
+}
+
+func (cli *cli) dstvPackage(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: dstv package needs a customer number", errUsage)
+	}
+
+	customerNumber, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid customer number [%s]", errUsage, args[0])
+	}
+
+	client, err := cli.newClient()
+	if err != nil {
+		return err
+	}
+
+	dstvPackage, _, err := client.Bills.GetDStvPackage(context.Background(), customerNumber)
+	if err != nil {
+		return err
+	}
+
+	packageName := ""
+	if dstvPackage != nil {
+		packageName = *dstvPackage
+	}
+
+	return cli.print(map[string]string{"package_name": packageName}, row{"Package", packageName})
+}
+
+func (cli *cli) dstvPay(args []string) error {
+	options := new(mobilenig.PayDstvOptions)
+	var productCode string
+	var confirm, dryRun bool
+
+	flags := flag.NewFlagSet("mobilenig dstv pay", flag.ContinueOnError)
+	flags.SetOutput(cli.stderr)
+	flags.StringVar(&options.SmartcardNumber, "smartcard", "", "the DStv smartcard number")
+	flags.StringVar(&productCode, "product", "", "the DStv product code e.g COMPE36")
+	flags.StringVar(&options.Price, "price", "", "the price of the product")
+	flags.StringVar(&options.CustomerName, "customer-name", "", "the name of the customer")
+	flags.StringVar(&options.CustomerNumber, "customer-number", "", "the DStv customer number")
+	flags.StringVar(&options.TransactionID, "trans-id", "", "the transaction ID, it is generated when empty")
+	flags.BoolVar(&dryRun, "dry-run", false, "print the request without sending it")
+	flags.BoolVar(&confirm, "confirm", false, "confirm that the payment can be made in the live environment")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	options.ProductCode = mobilenig.DstvProductCode(productCode)
+
+	clientOptions := []mobilenig.ClientOption{}
+	if dryRun {
+		clientOptions = append(clientOptions, mobilenig.WithDryRun())
+	}
+	if confirm {
+		clientOptions = append(clientOptions, mobilenig.WithLivePaymentsUnlocked())
+	}
+
+	client, err := cli.newClient(clientOptions...)
+	if err != nil {
+		return err
+	}
+
+	if options.TransactionID == "" {
+		if options.TransactionID, err = client.NewTransactionID(); err != nil {
+			return err
+		}
+	}
+
+	if err = options.Validate(); err != nil {
+		return err
+	}
+
+	transaction, resp, err := client.Bills.PayDStv(context.Background(), options)
+	if errors.Is(err, mobilenig.ErrDryRun) {
+		return cli.print(resp.DryRun,
+			row{"Operation", resp.DryRun.Operation},
+			row{"Method", resp.DryRun.Method},
+			row{"URL", resp.DryRun.URL},
+		)
+	}
+
+	if errors.Is(err, mobilenig.ErrLivePaymentsLocked) {
+		return fmt.Errorf("%w, run the command again with --confirm to make the payment", err)
+	}
+
+	switch {
+	case errors.Is(err, mobilenig.ErrPaymentNotSent):
+		return fmt.Errorf("payment [%s] was not sent: %w", options.TransactionID, err)
+	case err != nil && resp != nil && resp.Error != nil:
+		return fmt.Errorf("payment [%s] failed: %w", options.TransactionID, err)
+	case err != nil:
+		return fmt.Errorf("the outcome of payment [%s] is not known: %w, run `mobilenig dstv query %s` to check its status", options.TransactionID, err, options.TransactionID)
+	}
+
+	return cli.printTransaction(transaction)
+}
+
+func (cli *cli) dstvQuery(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: dstv query needs a transaction ID", errUsage)
+	}
+
+	client, err := cli.newClient()
+	if err != nil {
+		return err
+	}
+
+	transaction, _, err := client.Bills.QueryDStv(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	return cli.printTransaction(transaction)
+}
+
+func (cli *cli) printTransaction(transaction *mobilenig.DStvTransaction) error {
+	return cli.print(transaction,
+		row{"Transaction ID", transaction.TransactionID},
+		row{"Status", transaction.Details.Status},
+		row{"Service", transaction.Details.Service},
+		row{"Package", transaction.Details.Package},
+		row{"Smartcard", transaction.Details.SmartcardNumber},
+		row{"Price", transaction.Details.Price},
+		row{"Balance", transaction.Details.Balance},
+	)
+}
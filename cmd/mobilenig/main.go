@@ -0,0 +1,114 @@
+// Command mobilenig calls the MobileNig API from the command line.
+//
+// Credentials are read from the MOBILENIG_* environment variables, or from a profile which is a JSON file with the same
+// fields as mobilenig.Config stored in the mobilenig directory of the user config directory e.g ~/.config/mobilenig/ops.json.
+//
+// Usage:
+//
+//	mobilenig [--profile name | --config path] [--output table|json] <command> [arguments]
+//
+// Commands:
+//
+//	dstv check <smartcard>
+//	dstv package <customer_number>
+//	dstv pay --smartcard <smartcard> --product <code> --price <price> --customer-name <name> --customer-number <number>
+//	dstv query <trans_id>
+//	batch pay --file <payments.csv> [--state <path>] [--results <path>] [--concurrency 4] [--yes] [--confirm]
+//	balance
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errUsage is returned when the command line arguments are invalid
+var errUsage = errors.New("invalid usage")
+
+const usage = `Usage: mobilenig [--profile name | --config path] [--output table|json] <command> [arguments]
+
+Commands:
+  dstv check <smartcard>            validate a DStv smartcard number
+  dstv package <customer_number>    fetch the current DStv package of a customer
+  dstv pay [flags]                  pay a DStv subscription, run "mobilenig dstv pay --help" for the flags
+  dstv query <trans_id>             fetch a DStv transaction
+  batch pay --file <payments.csv>   pay the DStv subscriptions in a CSV file, run "mobilenig batch pay --help" for the flags
+  balance                           fetch the wallet balance
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	cli := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	flags := flag.NewFlagSet("mobilenig", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { _, _ = fmt.Fprint(stderr, usage) }
+	flags.StringVar(&cli.profile, "profile", os.Getenv(envProfile), "the name of the profile which contains the credentials")
+	flags.StringVar(&cli.configPath, "config", "", "the path of a JSON config file which contains the credentials")
+	flags.StringVar(&cli.output, "output", outputTable, "the output format, table or json")
+
+	if err := flags.Parse(args); err != nil {
+		return exitCode(err)
+	}
+
+	if cli.output != outputTable && cli.output != outputJSON {
+		_, _ = fmt.Fprintf(stderr, "mobilenig: invalid output [%s], it must be table or json\n", cli.output)
+		return 2
+	}
+
+	err := cli.execute(flags.Args())
+	if err != nil && err != errUsage && !errors.Is(err, flag.ErrHelp) {
+		_, _ = fmt.Fprintln(stderr, "mobilenig:", err)
+	}
+	if errors.Is(err, errUsage) {
+		_, _ = fmt.Fprint(stderr, usage)
+	}
+	return exitCode(err)
+}
+
+func exitCode(err error) int {
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// cli contains the global flags and the output of the command line
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	profile    string
+	configPath string
+	output     string
+}
+
+// execute runs the command in args
+func (cli *cli) execute(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "dstv":
+		return cli.dstv(args[1:])
+	case "batch":
+		return cli.batch(args[1:])
+	case "balance":
+		return cli.balance(args[1:])
+	default:
+		return fmt.Errorf("%w: unknown command [%s]", errUsage, args[0])
+	}
+}
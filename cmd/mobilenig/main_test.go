@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go"
+	"github.com/NdoleStudio/mobilenig-go/mobilenigtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestConfig writes a config file for the server and returns its path
+func newTestConfig(t *testing.T, server *mobilenigtest.Server) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	contents, _ := json.Marshal(map[string]string{
+		"username": "mobilenigtest",
+		"api_key":  "mobilenigtest",
+		"base_url": server.URL().String(),
+	})
+	assert.NoError(t, ioutil.WriteFile(path, contents, 0o600))
+	return path
+}
+
+func newTestServer() *mobilenigtest.Server {
+	return mobilenigtest.NewServer(
+		mobilenigtest.WithBalance(5000),
+		mobilenigtest.WithSmartcards(mobilenigtest.Smartcard{
+			Number:         "4131953321",
+			CustomerNumber: 275953782,
+			FirstName:      "ESU",
+			LastName:       "INI OBONG BASSEY",
+			PackageName:    "DStv Compact",
+		}),
+	)
+}
+
+func runTest(args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := run(args, strings.NewReader(""), stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	config := newTestConfig(t, server)
+
+	t.Run("dstv check prints a table", func(t *testing.T) {
+		// Act
+		code, stdout, _ := runTest("--config", config, "dstv", "check", "4131953321")
+
+		// Assert
+		assert.Equal(t, 0, code)
+		assert.Contains(t, stdout, "Customer number  275953782")
+	})
+
+	t.Run("dstv pay and dstv query print JSON", func(t *testing.T) {
+		// Act
+		code, stdout, stderr := runTest(
+			"--config", config, "--output", "json", "dstv", "pay",
+			"--smartcard", "4131953321", "--product", "COMPE36", "--price", "2000",
+			"--customer-name", "ESU INI OBONG BASSEY", "--customer-number", "275953782", "--trans-id", "1",
+		)
+		queryCode, queryStdout, _ := runTest("--config", config, "--output", "json", "dstv", "query", "1")
+
+		// Assert
+		assert.Equal(t, 0, code, stderr)
+		assert.Contains(t, stdout, `"status": "SUCCESSFUL"`)
+		assert.Equal(t, 0, queryCode)
+		assert.Equal(t, stdout, queryStdout)
+	})
+
+	t.Run("dstv pay validates the flags", func(t *testing.T) {
+		// Act
+		code, _, stderr := runTest("--config", config, "dstv", "pay", "--smartcard", "4131953321")
+
+		// Assert
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "invalid [price]")
+	})
+
+	t.Run("balance", func(t *testing.T) {
+		// Act
+		code, stdout, _ := runTest("--config", config, "balance")
+
+		// Assert
+		assert.Equal(t, 0, code)
+		assert.Contains(t, stdout, "Balance  3000")
+	})
+
+	t.Run("unknown commands print the usage", func(t *testing.T) {
+		// Act
+		code, _, stderr := runTest("--config", config, "airtime")
+
+		// Assert
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "Usage: mobilenig")
+	})
+
+	// Teardown
+	server.Close()
+}
+
+func TestRun_DstvPayErrors(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	config := newTestConfig(t, server)
+	pay := func(transactionID string, smartcard string) (int, string) {
+		code, _, stderr := runTest(
+			"--config", config, "dstv", "pay", "--smartcard", smartcard, "--product", "COMPE36", "--price", "2000",
+			"--customer-name", "ESU INI OBONG BASSEY", "--customer-number", "275953782", "--trans-id", transactionID,
+		)
+		return code, stderr
+	}
+
+	t.Run("a payment rejected by MobileNig failed", func(t *testing.T) {
+		// Act
+		code, stderr := pay("1", "7027914329")
+
+		// Assert
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "payment [1] failed")
+	})
+
+	t.Run("a payment without a response has an unknown outcome", func(t *testing.T) {
+		// Arrange
+		server.InjectFailure(mobilenig.OperationPayDStv, mobilenigtest.Failure{
+			StatusCode:      http.StatusBadGateway,
+			Body:            "<html>Bad Gateway</html>",
+			AfterProcessing: true,
+		}, 1)
+
+		// Act
+		code, stderr := pay("2", "4131953321")
+
+		// Assert
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "the outcome of payment [2] is not known")
+		assert.Contains(t, stderr, "mobilenig dstv query 2")
+	})
+
+	// Teardown
+	server.Close()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// row is a field and its value in a table
+type row struct {
+	field string
+	value interface{}
+}
+
+// print writes value as indented JSON or the rows as a table
+func (cli *cli) print(value interface{}, rows ...row) error {
+	if cli.output == outputJSON {
+		encoder := json.NewEncoder(cli.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	writer := tabwriter.NewWriter(cli.stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		if _, err := fmt.Fprintf(writer, "%s\t%v\n", row.field, row.value); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
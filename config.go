@@ -0,0 +1,179 @@
+package mobilenig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variables which are read by NewFromEnv
+const (
+	EnvEnvironment    = "MOBILENIG_ENVIRONMENT"
+	EnvBaseURL        = "MOBILENIG_BASE_URL"
+	EnvTimeout        = "MOBILENIG_TIMEOUT"
+	EnvMaxRetries     = "MOBILENIG_MAX_RETRIES"
+	EnvRetryBackoff   = "MOBILENIG_RETRY_BACKOFF"
+	EnvRateLimit      = "MOBILENIG_RATE_LIMIT"
+	EnvRateLimitBurst = "MOBILENIG_RATE_LIMIT_BURST"
+)
+
+// Config is the configuration of a Client which is read from the environment or a JSON file.
+// Durations are strings which can be parsed by time.ParseDuration e.g "30s".
+type Config struct {
+	Username       string  `json:"username"`
+	APIKey         string  `json:"api_key"`
+	Environment    string  `json:"environment"`
+	BaseURL        string  `json:"base_url"`
+	Timeout        string  `json:"timeout"`
+	MaxRetries     int     `json:"max_retries"`
+	RetryBackoff   string  `json:"retry_backoff"`
+	RateLimit      float64 `json:"rate_limit"`
+	RateLimitBurst int     `json:"rate_limit_burst"`
+}
+
+// NewFromEnv creates a Client using the MOBILENIG_* environment variables.
+// The options are applied after the configuration so they can override it.
+func NewFromEnv(options ...ClientOption) (*Client, error) {
+	config, err := configFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return config.newClient(options...)
+}
+
+// NewFromConfigFile creates a Client using the Config in a JSON file.
+// The options are applied after the configuration so they can override it.
+func NewFromConfigFile(path string, options ...ClientOption) (*Client, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := new(Config)
+	if err = json.Unmarshal(contents, config); err != nil {
+		return nil, fmt.Errorf("mobilenig: invalid config file [%s]: %w", path, err)
+	}
+
+	return config.newClient(options...)
+}
+
+func configFromEnv() (*Config, error) {
+	config := &Config{
+		Username:     os.Getenv(EnvUsername),
+		APIKey:       os.Getenv(EnvAPIKey),
+		Environment:  os.Getenv(EnvEnvironment),
+		BaseURL:      os.Getenv(EnvBaseURL),
+		Timeout:      os.Getenv(EnvTimeout),
+		RetryBackoff: os.Getenv(EnvRetryBackoff),
+	}
+
+	var err error
+	if value := os.Getenv(EnvMaxRetries); value != "" {
+		if config.MaxRetries, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("mobilenig: invalid %s [%s]: %w", EnvMaxRetries, value, err)
+		}
+	}
+
+	if value := os.Getenv(EnvRateLimit); value != "" {
+		if config.RateLimit, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("mobilenig: invalid %s [%s]: %w", EnvRateLimit, value, err)
+		}
+	}
+
+	if value := os.Getenv(EnvRateLimitBurst); value != "" {
+		if config.RateLimitBurst, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("mobilenig: invalid %s [%s]: %w", EnvRateLimitBurst, value, err)
+		}
+	}
+
+	return config, nil
+}
+
+// Options validates the configuration and converts it into ClientOption
+func (config *Config) Options() ([]ClientOption, error) {
+	if config.APIKey == "" {
+		return nil, errors.New("mobilenig: the API key is missing")
+	}
+
+	if config.Username == "" {
+		return nil, errors.New("mobilenig: the username is missing")
+	}
+
+	options := []ClientOption{WithUsername(config.Username), WithAPIKey(config.APIKey)}
+
+	if config.Environment != "" {
+		environment, err := ParseEnvironment(config.Environment)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithEnvironment(environment))
+	}
+
+	if config.BaseURL != "" {
+		baseURL, err := url.Parse(config.BaseURL)
+		if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+			return nil, fmt.Errorf("mobilenig: invalid base URL [%s]", config.BaseURL)
+		}
+		options = append(options, WithBaseURL(baseURL))
+	}
+
+	if config.Timeout != "" {
+		timeout, err := parsePositiveDuration("timeout", config.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithHTTPClient(&http.Client{Timeout: timeout}))
+	}
+
+	if config.MaxRetries < 0 {
+		return nil, fmt.Errorf("mobilenig: invalid max retries [%d], it cannot be negative", config.MaxRetries)
+	}
+
+	if config.MaxRetries > 0 {
+		backoff := 500 * time.Millisecond
+		if config.RetryBackoff != "" {
+			var err error
+			if backoff, err = parsePositiveDuration("retry backoff", config.RetryBackoff); err != nil {
+				return nil, err
+			}
+		}
+		options = append(options, WithRetryPolicy(RetryPolicy{MaxRetries: config.MaxRetries, Backoff: backoff}))
+	}
+
+	if config.RateLimit < 0 || config.RateLimitBurst < 0 {
+		return nil, errors.New("mobilenig: the rate limit cannot be negative")
+	}
+
+	if config.RateLimit > 0 {
+		options = append(options, WithRateLimit(config.RateLimit, config.RateLimitBurst))
+	}
+
+	return options, nil
+}
+
+func (config *Config) newClient(options ...ClientOption) (*Client, error) {
+	configOptions, err := config.Options()
+	if err != nil {
+		return nil, err
+	}
+	return New(append(configOptions, options...)...), nil
+}
+
+func parsePositiveDuration(name string, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("mobilenig: invalid %s [%s]: %w", name, value, err)
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("mobilenig: invalid %s [%s], it must be positive", name, value)
+	}
+
+	return duration, nil
+}
@@ -0,0 +1,129 @@
+package mobilenig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setTestEnv(t *testing.T, values map[string]string) {
+	for key, value := range values {
+		_ = os.Setenv(key, value)
+	}
+
+	t.Cleanup(func() {
+		for key := range values {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
+func TestParseEnvironment(t *testing.T) {
+	environment, err := ParseEnvironment("test")
+	assert.NoError(t, err)
+	assert.Equal(t, TestEnvironment, environment)
+
+	environment, err = ParseEnvironment(" LIVE ")
+	assert.NoError(t, err)
+	assert.Equal(t, LiveEnvironment, environment)
+
+	_, err = ParseEnvironment("STAGING")
+	assert.EqualError(t, err, "mobilenig: unknown environment [STAGING], expected [LIVE] or [TEST]")
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Run("the client is configured from the environment", func(t *testing.T) {
+		// Arrange
+		setTestEnv(t, map[string]string{
+			EnvUsername:       testUsername,
+			EnvAPIKey:         testAPIKey,
+			EnvEnvironment:    "test",
+			EnvBaseURL:        "http://localhost:8080/API",
+			EnvTimeout:        "15s",
+			EnvMaxRetries:     "3",
+			EnvRetryBackoff:   "100ms",
+			EnvRateLimit:      "5",
+			EnvRateLimitBurst: "2",
+		})
+
+		// Act
+		client, err := NewFromEnv()
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, TestEnvironment, client.environment)
+		assert.Equal(t, "http://localhost:8080/API", client.baseURL)
+		assert.Equal(t, 15*time.Second, client.httpClient.Timeout)
+		assert.Equal(t, &RetryPolicy{MaxRetries: 3, Backoff: 100 * time.Millisecond}, client.retry)
+		assert.NotNil(t, client.rateLimiter)
+		assert.Equal(t, 2.0, client.rateLimiter.burst)
+	})
+
+	t.Run("an error is returned when the API key is missing", func(t *testing.T) {
+		// Arrange
+		setTestEnv(t, map[string]string{EnvUsername: testUsername})
+
+		// Act
+		_, err := NewFromEnv()
+
+		// Assert
+		assert.EqualError(t, err, "mobilenig: the API key is missing")
+	})
+
+	t.Run("an error is returned for an invalid number", func(t *testing.T) {
+		// Arrange
+		setTestEnv(t, map[string]string{EnvUsername: testUsername, EnvAPIKey: testAPIKey, EnvMaxRetries: "three"})
+
+		// Act
+		_, err := NewFromEnv()
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestNewFromConfigFile(t *testing.T) {
+	t.Run("the client is configured from the file", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "mobilenig.json")
+		_ = ioutil.WriteFile(path, []byte(`{"username":"user","api_key":"key","environment":"LIVE","timeout":"1m"}`), 0o600)
+
+		// Act
+		client, err := NewFromConfigFile(path, WithEnvironment(TestEnvironment))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, TestEnvironment, client.environment)
+		assert.Equal(t, time.Minute, client.httpClient.Timeout)
+		assert.Equal(t, apiBaseURL, client.baseURL)
+		assert.Nil(t, client.retry)
+		assert.Nil(t, client.rateLimiter)
+	})
+
+	tests := map[string]string{
+		"unknown environment": `{"username":"user","api_key":"key","environment":"SANDBOX"}`,
+		"missing username":    `{"api_key":"key"}`,
+		"invalid base URL":    `{"username":"user","api_key":"key","base_url":"mobilenig"}`,
+		"invalid timeout":     `{"username":"user","api_key":"key","timeout":"-1s"}`,
+		"negative retries":    `{"username":"user","api_key":"key","max_retries":-1}`,
+		"invalid JSON":        `{"username":`,
+	}
+	for name, contents := range tests {
+		contents := contents
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			path := filepath.Join(t.TempDir(), "mobilenig.json")
+			_ = ioutil.WriteFile(path, []byte(contents), 0o600)
+
+			// Act
+			_, err := NewFromConfigFile(path)
+
+			// Assert
+			assert.Error(t, err)
+		})
+	}
+}
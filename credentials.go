@@ -0,0 +1,138 @@
+package mobilenig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// EnvUsername is the environment variable which contains the MobileNig username
+	EnvUsername = "MOBILENIG_USERNAME"
+
+	// EnvAPIKey is the environment variable which contains the MobileNig API key
+	EnvAPIKey = "MOBILENIG_API_KEY"
+)
+
+// Credentials are the MobileNig API credentials
+type Credentials struct {
+	Username string `json:"username"`
+	APIKey   string `json:"api_key"`
+}
+
+// CredentialsProvider returns the credentials which are used for a request.
+// It is called before every request so rotated credentials are used without creating a new Client.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialsProviderFunc is an adapter to allow the use of ordinary functions as a CredentialsProvider
+type CredentialsProviderFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials calls fn(ctx)
+func (fn CredentialsProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return fn(ctx)
+}
+
+// StaticCredentials returns a CredentialsProvider which always returns the same credentials
+func StaticCredentials(username string, apiKey string) CredentialsProvider {
+	return CredentialsProviderFunc(func(_ context.Context) (Credentials, error) {
+		return Credentials{Username: username, APIKey: apiKey}, nil
+	})
+}
+
+// EnvCredentials returns a CredentialsProvider which reads the credentials from environment variables on every request.
+// EnvUsername and EnvAPIKey are used when the variable names are empty.
+func EnvCredentials(usernameVariable string, apiKeyVariable string) CredentialsProvider {
+	if usernameVariable == "" {
+		usernameVariable = EnvUsername
+	}
+	if apiKeyVariable == "" {
+		apiKeyVariable = EnvAPIKey
+	}
+
+	return CredentialsProviderFunc(func(_ context.Context) (Credentials, error) {
+		credentials := Credentials{Username: os.Getenv(usernameVariable), APIKey: os.Getenv(apiKeyVariable)}
+		if credentials.APIKey == "" {
+			return credentials, fmt.Errorf("mobilenig: the environment variable [%s] is not set", apiKeyVariable)
+		}
+		return credentials, nil
+	})
+}
+
+// FileCredentialsProvider reads the credentials from a JSON file e.g {"username": "", "api_key": ""}.
+// The file is read again when its modification time or size changes, so keys can be rotated by replacing the file.
+type FileCredentialsProvider struct {
+	path string
+
+	mu          sync.RWMutex
+	credentials Credentials
+	modTime     time.Time
+	size        int64
+	loaded      bool
+}
+
+// NewFileCredentials creates a FileCredentialsProvider which watches the file at path
+func NewFileCredentials(path string) (*FileCredentialsProvider, error) {
+	provider := &FileCredentialsProvider{path: path}
+	if _, err := provider.Credentials(context.Background()); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// Credentials returns the credentials in the file, reloading it when it has changed.
+// The last valid credentials are returned if the file cannot be read while it is being replaced.
+func (provider *FileCredentialsProvider) Credentials(_ context.Context) (Credentials, error) {
+	info, err := os.Stat(provider.path)
+	if err != nil {
+		return provider.cached(err)
+	}
+
+	provider.mu.RLock()
+	changed := !provider.loaded || !info.ModTime().Equal(provider.modTime) || info.Size() != provider.size
+	credentials := provider.credentials
+	provider.mu.RUnlock()
+
+	if !changed {
+		return credentials, nil
+	}
+
+	contents, err := ioutil.ReadFile(provider.path)
+	if err != nil {
+		return provider.cached(err)
+	}
+
+	credentials = Credentials{}
+	if err = json.Unmarshal(contents, &credentials); err != nil {
+		return provider.cached(fmt.Errorf("mobilenig: invalid credentials file [%s]: %w", provider.path, err))
+	}
+
+	if credentials.APIKey == "" {
+		return provider.cached(fmt.Errorf("mobilenig: the credentials file [%s] has no api_key", provider.path))
+	}
+
+	provider.mu.Lock()
+	provider.credentials = credentials
+	provider.modTime = info.ModTime()
+	provider.size = info.Size()
+	provider.loaded = true
+	provider.mu.Unlock()
+
+	return credentials, nil
+}
+
+// cached returns the last valid credentials or err if the file has never been loaded
+func (provider *FileCredentialsProvider) cached(err error) (Credentials, error) {
+	provider.mu.RLock()
+	defer provider.mu.RUnlock()
+
+	if !provider.loaded {
+		return Credentials{}, err
+	}
+	return provider.credentials, nil
+}
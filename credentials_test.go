@@ -0,0 +1,109 @@
+package mobilenig
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/helpers"
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticCredentials(t *testing.T) {
+	// Act
+	credentials, err := StaticCredentials(testUsername, testAPIKey).Credentials(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{Username: testUsername, APIKey: testAPIKey}, credentials)
+}
+
+func TestEnvCredentials(t *testing.T) {
+	t.Run("credentials are read from the default variables", func(t *testing.T) {
+		// Arrange
+		_ = os.Setenv(EnvUsername, testUsername)
+		_ = os.Setenv(EnvAPIKey, testAPIKey)
+		defer func() {
+			_ = os.Unsetenv(EnvUsername)
+			_ = os.Unsetenv(EnvAPIKey)
+		}()
+
+		// Act
+		credentials, err := EnvCredentials("", "").Credentials(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, Credentials{Username: testUsername, APIKey: testAPIKey}, credentials)
+	})
+
+	t.Run("an error is returned when the api key is not set", func(t *testing.T) {
+		// Act
+		_, err := EnvCredentials("MOBILENIG_TEST_MISSING_USERNAME", "MOBILENIG_TEST_MISSING_API_KEY").Credentials(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestFileCredentialsProvider(t *testing.T) {
+	t.Run("rotated credentials are used without creating a new client", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		_ = ioutil.WriteFile(path, []byte(`{"username":"user","api_key":"old-key"}`), 0o600)
+
+		provider, err := NewFileCredentials(path)
+		assert.NoError(t, err)
+
+		request := new(http.Request)
+		server := helpers.MakeRequestCapturingTestServer(http.StatusOK, stubs.CheckDstvUserResponse(), request)
+		baseURL, _ := url.Parse(server.URL)
+		client := New(WithBaseURL(baseURL), WithCredentialsProvider(provider))
+
+		// Act
+		_, _, _ = client.Bills.CheckDStvUser(context.Background(), "4131953321")
+		oldKey := request.URL.Query().Get("api_key")
+
+		_ = ioutil.WriteFile(path, []byte(`{"username":"user","api_key":"new-api-key"}`), 0o600)
+		_ = os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+
+		_, _, _ = client.Bills.CheckDStvUser(context.Background(), "4131953321")
+		newKey := request.URL.Query().Get("api_key")
+
+		// Assert
+		assert.Equal(t, "old-key", oldKey)
+		assert.Equal(t, "new-api-key", newKey)
+		assert.Equal(t, "user", request.URL.Query().Get("username"))
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("the last valid credentials are used when the file is invalid", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		_ = ioutil.WriteFile(path, []byte(`{"username":"user","api_key":"key"}`), 0o600)
+		provider, _ := NewFileCredentials(path)
+
+		// Act
+		_ = ioutil.WriteFile(path, []byte(`{"username":`), 0o600)
+		credentials, err := provider.Credentials(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "key", credentials.APIKey)
+	})
+
+	t.Run("an error is returned when the file does not exist", func(t *testing.T) {
+		// Act
+		_, err := NewFileCredentials(filepath.Join(t.TempDir(), "missing.json"))
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,72 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDryRun is returned by payments which were not sent because dry-run mode is enabled.
+// The request which would have been sent is available in Response.DryRun.
+var ErrDryRun = errors.New("mobilenig: dry run, the request was not sent")
+
+// DryRunResult is the request which would have been sent for a payment in dry-run mode.
+// The API key is redacted from the URL and the params.
+type DryRunResult struct {
+	Operation Operation         `json:"operation"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Params    map[string]string `json:"params"`
+}
+
+type dryRunContextKey struct{}
+
+// ContextWithDryRun returns a context which enables dry-run mode for the payments which are made with it
+func ContextWithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunContextKey{}, true)
+}
+
+// isDryRun returns true when dry-run mode is enabled for the client or the context
+func (client *Client) isDryRun(ctx context.Context) bool {
+	enabled, _ := ctx.Value(dryRunContextKey{}).(bool)
+	return client.dryRun || enabled
+}
+
+// dryRunPayment validates a payment and builds the request which would be sent without sending it
+func (client *Client) dryRunPayment(ctx context.Context, payment *payment) (*Response, error) {
+	if payment.validate != nil {
+		if err := payment.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := client.newRequest(ctx, payment.endpoint, payment.params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DryRunResult{
+		Operation: payment.endpoint.operation,
+		Method:    request.Method,
+		URL:       redactURL(request.URL, secretParams...),
+		Params:    make(map[string]string),
+	}
+
+	params := redactParams(request.URL.Query(), secretParams...)
+	for key := range params {
+		result.Params[key] = params.Get(key)
+	}
+
+	return &Response{DryRun: result}, ErrDryRun
+}
+
+// ValidationError is returned when a field of the options of a request is invalid
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+// Error returns the error message
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("mobilenig: invalid [%s]: %s", err.Field, err.Message)
+}
@@ -0,0 +1,120 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDryRunTestOptions() *PayDstvOptions {
+	return &PayDstvOptions{
+		TransactionID:   "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+		Price:           "2000",
+		ProductCode:     DstvProductCodeCompact,
+		CustomerName:    "John Doe",
+		CustomerNumber:  "275953782",
+		SmartcardNumber: "4131953321",
+	}
+}
+
+func TestBillsService_PayDStvDryRun(t *testing.T) {
+	// Arrange
+	requests := 0
+	httpClient := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return nil, errors.New("network is disabled")
+	})}
+	store := NewMemoryLedgerStore()
+
+	t.Run("the request is returned without being sent", func(t *testing.T) {
+		// Arrange
+		client := New(WithHTTPClient(httpClient), WithAPIKey("secret"), WithUsername("user"), WithLedger(store), WithDryRun())
+
+		// Act
+		transaction, resp, err := client.Bills.PayDStv(context.Background(), newDryRunTestOptions())
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDryRun))
+		assert.Nil(t, transaction)
+		assert.Equal(t, 0, requests)
+
+		assert.Equal(t, OperationPayDStv, resp.DryRun.Operation)
+		assert.Equal(t, http.MethodGet, resp.DryRun.Method)
+		assert.True(t, strings.HasPrefix(resp.DryRun.URL, apiBaseURL))
+		assert.NotContains(t, resp.DryRun.URL, "secret")
+		assert.Equal(t, redacted, resp.DryRun.Params["api_key"])
+		assert.Equal(t, "user", resp.DryRun.Params["username"])
+		assert.Equal(t, "4131953321", resp.DryRun.Params["smartno"])
+
+		entries, _ := store.List(context.Background())
+		assert.Empty(t, entries)
+	})
+
+	t.Run("dry-run mode can be enabled with the context", func(t *testing.T) {
+		// Arrange
+		client := New(WithHTTPClient(httpClient), WithEnvironment(TestEnvironment))
+
+		// Act
+		_, resp, err := client.Bills.PayDStv(ContextWithDryRun(context.Background()), newDryRunTestOptions())
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrDryRun))
+		assert.Contains(t, resp.DryRun.URL, endpointPayDStv.sandboxURI)
+		assert.Equal(t, 0, requests)
+	})
+
+	t.Run("invalid options are rejected", func(t *testing.T) {
+		// Arrange
+		client := New(WithHTTPClient(httpClient), WithDryRun())
+		options := newDryRunTestOptions()
+		options.SmartcardNumber = "41319-53321"
+
+		// Act
+		_, resp, err := client.Bills.PayDStv(context.Background(), options)
+
+		// Assert
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "smartno", validationErr.Field)
+		assert.Nil(t, resp)
+		assert.Equal(t, 0, requests)
+	})
+}
+
+func TestPayDstvOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(options *PayDstvOptions)
+		field  string
+	}{
+		{name: "empty price", modify: func(options *PayDstvOptions) { options.Price = "" }, field: "price"},
+		{name: "negative price", modify: func(options *PayDstvOptions) { options.Price = "-20" }, field: "price"},
+		{name: "NaN price", modify: func(options *PayDstvOptions) { options.Price = "NaN" }, field: "price"},
+		{name: "infinite price", modify: func(options *PayDstvOptions) { options.Price = "Inf" }, field: "price"},
+		{name: "empty product code", modify: func(options *PayDstvOptions) { options.ProductCode = "" }, field: "product_code"},
+		{name: "empty customer number", modify: func(options *PayDstvOptions) { options.CustomerNumber = " " }, field: "customer_number"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			options := newDryRunTestOptions()
+			test.modify(options)
+
+			// Act
+			err := options.Validate()
+
+			// Assert
+			var validationErr *ValidationError
+			assert.True(t, errors.As(err, &validationErr))
+			assert.Equal(t, test.field, validationErr.Field)
+		})
+	}
+
+	assert.Nil(t, newDryRunTestOptions().Validate())
+}
@@ -1,6 +1,11 @@
 package mobilenig
 
-import "time"
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // DstvProductCode is a code for DStv packages
 type DstvProductCode string
@@ -28,7 +33,24 @@ const (
 	DstvProductCodePremiumXtraView DstvProductCode = "DPRHDP"
 )
 
-// PayDstvOptions is the input used when paying a DStv subscription
+// dstvProductCodes are the known DStv product codes
+var dstvProductCodes = []DstvProductCode{
+	DstvProductCodePadi,
+	DstvYangaBouquet,
+	DstvProductCodeCompact,
+	DstvProductCodeCompactPlus,
+	DstvProductCodeCompactPlusXtraView,
+	DstvProductCodePremium,
+	DstvProductCodePremiumXtraView,
+}
+
+// IsKnown returns true when the code is one of the DstvProductCode constants
+func (code DstvProductCode) IsKnown() bool {
+	return containsProductCode(dstvProductCodes, string(code))
+}
+
+// PayDstvOptions is the input used when paying a DStv subscription.
+// The TransactionID must be unique, it is generated by the client when it is empty.
 type PayDstvOptions struct {
 	TransactionID   string          `json:"trans_id"`
 	Price           string          `json:"price"`
@@ -38,6 +60,63 @@ type PayDstvOptions struct {
 	SmartcardNumber string          `json:"smartno"`
 }
 
+// params returns the query parameters of the payment request
+func (options *PayDstvOptions) params() map[string]string {
+	return map[string]string{
+		"product_code":    string(options.ProductCode),
+		"customer_name":   options.CustomerName,
+		"customer_number": options.CustomerNumber,
+		"price":           options.Price,
+		"smartno":         options.SmartcardNumber,
+		"trans_id":        options.TransactionID,
+	}
+}
+
+// Validate checks that the options contain all the fields which are required to pay a DStv subscription
+func (options *PayDstvOptions) Validate() error {
+	required := []struct {
+		field string
+		value string
+	}{
+		{field: "trans_id", value: options.TransactionID},
+		{field: "price", value: options.Price},
+		{field: "product_code", value: string(options.ProductCode)},
+		{field: "customer_name", value: options.CustomerName},
+		{field: "customer_number", value: options.CustomerNumber},
+		{field: "smartno", value: options.SmartcardNumber},
+	}
+
+	for _, param := range required {
+		if strings.TrimSpace(param.value) == "" {
+			return &ValidationError{Field: param.field, Message: "cannot be empty"}
+		}
+	}
+
+	if price, err := strconv.ParseFloat(options.Price, 64); err != nil || math.IsNaN(price) || math.IsInf(price, 0) || price <= 0 {
+		return &ValidationError{Field: "price", Message: "[" + options.Price + "] is not a positive amount"}
+	}
+
+	for _, digit := range options.SmartcardNumber {
+		if digit < '0' || digit > '9' {
+			return &ValidationError{Field: "smartno", Message: "[" + options.SmartcardNumber + "] must contain only digits"}
+		}
+	}
+
+	return nil
+}
+
+// payDstvOptionsFromParams creates PayDstvOptions from the query parameters of a payment request
+func payDstvOptionsFromParams(params map[string]string) *PayDstvOptions {
+	return &PayDstvOptions{
+		TransactionID:   params["trans_id"],
+		Price:           params["price"],
+		ProductCode:     DstvProductCode(params["product_code"]),
+		CustomerName:    params["customer_name"],
+		CustomerNumber:  params["customer_number"],
+		SmartcardNumber: params["smartno"],
+	}
+}
+
 // DStvUser is a dstv subscription customer
 type DStvUser struct {
 	Details struct {
@@ -51,6 +130,11 @@ type DStvUser struct {
 	} `json:"details"`
 }
 
+// dstvPackageDetails is the response of GetDStvPackage
+type dstvPackageDetails struct {
+	PackageName *string `json:"packageName"`
+}
+
 // DStvTransaction is the data about a DStv subscription payment
 type DStvTransaction struct {
 	TransactionID string `json:"trans_id"`
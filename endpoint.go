@@ -0,0 +1,100 @@
+package mobilenig
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrNoSandbox is returned when an operation is called in the TestEnvironment but it has no sandbox
+var ErrNoSandbox = errors.New("mobilenig: the operation has no sandbox")
+
+// Operation is the name of a MobileNig API operation e.g "PayDStv"
+type Operation string
+
+const (
+	// OperationCheckDStvUser validates a DStv smartcard number
+	OperationCheckDStvUser = Operation("CheckDStvUser")
+
+	// OperationGetDStvPackage fetches the current DStv package of a customer
+	OperationGetDStvPackage = Operation("GetDStvPackage")
+
+	// OperationPayDStv pays a DStv subscription
+	OperationPayDStv = Operation("PayDStv")
+
+	// OperationQueryDStv fetches a DStv transaction
+	OperationQueryDStv = Operation("QueryDStv")
+
+	// OperationGetBalance fetches the wallet balance
+	OperationGetBalance = Operation("GetBalance")
+)
+
+func (operation Operation) String() string {
+	return string(operation)
+}
+
+// endpoint maps an Operation to the URIs used in the LiveEnvironment and the TestEnvironment
+type endpoint struct {
+	operation Operation
+	uri       string
+
+	// sandboxURI is the URI used in the TestEnvironment. The operation has no sandbox when it is empty.
+	sandboxURI string
+
+	// moneyMoving is true for operations which debit the wallet
+	moneyMoving bool
+}
+
+var (
+	endpointCheckDStvUser  = endpoint{operation: OperationCheckDStvUser, uri: "/bills/user_check"}
+	endpointGetDStvPackage = endpoint{operation: OperationGetDStvPackage, uri: "/bills/get_package"}
+	endpointPayDStv        = endpoint{operation: OperationPayDStv, uri: "/bills/dstv", sandboxURI: "/bills/dstv_test", moneyMoving: true}
+	endpointQueryDStv      = endpoint{operation: OperationQueryDStv, uri: "/bills/query"}
+	endpointGetBalance     = endpoint{operation: OperationGetBalance, uri: "/balance"}
+)
+
+// endpointURL returns the URL of the endpoint for the environment of the client.
+// In the TestEnvironment, the sandbox URI of the endpoint is used. Operations without a sandbox are only allowed when the
+// base URL is a test host e.g. a local fake server, so tests can't accidentally touch production.
+func (client *Client) endpointURL(endpoint endpoint) (string, error) {
+	if client.environment != TestEnvironment {
+		return client.baseURL + endpoint.uri, nil
+	}
+
+	if endpoint.sandboxURI != "" {
+		return client.baseURL + endpoint.sandboxURI, nil
+	}
+
+	if client.isTestBaseURL() {
+		return client.baseURL + endpoint.uri, nil
+	}
+
+	return "", fmt.Errorf("%w: [%s] cannot be called in the [%s] environment", ErrNoSandbox, endpoint.operation, TestEnvironment)
+}
+
+// isTestBaseURL returns true when the base URL of the client points to a loopback address or a host which was
+// allowed with WithTestHosts. Every other base URL is treated as the MobileNig production API.
+func (client *Client) isTestBaseURL() bool {
+	baseURL, err := url.Parse(client.baseURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(baseURL.Hostname())
+	if host == "localhost" {
+		return true
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	for _, testHost := range client.testHosts {
+		if host == testHost {
+			return true
+		}
+	}
+	return false
+}
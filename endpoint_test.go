@@ -0,0 +1,84 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_EndpointURL(t *testing.T) {
+	localURL, _ := url.Parse("http://127.0.0.1:8080")
+	proxyURL, _ := url.Parse("https://proxy.example.com")
+
+	tests := []struct {
+		name     string
+		client   *Client
+		endpoint endpoint
+		expected string
+		err      error
+	}{
+		{
+			name:     "live endpoints are used in the live environment",
+			client:   New(),
+			endpoint: endpointPayDStv,
+			expected: apiBaseURL + "/bills/dstv",
+		},
+		{
+			name:     "the sandbox endpoint is used in the test environment",
+			client:   New(WithEnvironment(TestEnvironment)),
+			endpoint: endpointPayDStv,
+			expected: apiBaseURL + "/bills/dstv_test",
+		},
+		{
+			name:     "operations without a sandbox fail in the test environment",
+			client:   New(WithEnvironment(TestEnvironment)),
+			endpoint: endpointQueryDStv,
+			err:      ErrNoSandbox,
+		},
+		{
+			name:     "operations without a sandbox fail for a non test host in the test environment",
+			client:   New(WithEnvironment(TestEnvironment), WithBaseURL(proxyURL)),
+			endpoint: endpointCheckDStvUser,
+			err:      ErrNoSandbox,
+		},
+		{
+			name:     "operations without a sandbox use an allowed test host in the test environment",
+			client:   New(WithEnvironment(TestEnvironment), WithBaseURL(proxyURL), WithTestHosts("proxy.example.com")),
+			endpoint: endpointCheckDStvUser,
+			expected: "https://proxy.example.com/bills/user_check",
+		},
+		{
+			name:     "operations without a sandbox use a loopback base URL in the test environment",
+			client:   New(WithEnvironment(TestEnvironment), WithBaseURL(localURL)),
+			endpoint: endpointCheckDStvUser,
+			expected: "http://127.0.0.1:8080/bills/user_check",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Act
+			endpointURL, err := test.client.endpointURL(test.endpoint)
+
+			// Assert
+			assert.True(t, errors.Is(err, test.err))
+			assert.Equal(t, test.expected, endpointURL)
+		})
+	}
+}
+
+func TestBillsService_CheckDStvUser_NoSandbox(t *testing.T) {
+	// Arrange
+	client := New(WithEnvironment(TestEnvironment))
+
+	// Act
+	_, _, err := client.Bills.CheckDStvUser(context.Background(), "4131953321")
+
+	// Assert
+	assert.True(t, errors.Is(err, ErrNoSandbox))
+	assert.EqualError(t, err, "mobilenig: the operation has no sandbox: [CheckDStvUser] cannot be called in the [TEST] environment")
+}
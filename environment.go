@@ -1,5 +1,10 @@
 package mobilenig
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Environment is the URL of the mobilenig environment
 type Environment string
 
@@ -14,3 +19,13 @@ const (
 func (e Environment) String() string {
 	return string(e)
 }
+
+// ParseEnvironment converts a string e.g "TEST" into an Environment. The comparison is case-insensitive.
+func ParseEnvironment(value string) (Environment, error) {
+	switch environment := Environment(strings.ToUpper(strings.TrimSpace(value))); environment {
+	case LiveEnvironment, TestEnvironment:
+		return environment, nil
+	default:
+		return "", fmt.Errorf("mobilenig: unknown environment [%s], expected [%s] or [%s]", value, LiveEnvironment, TestEnvironment)
+	}
+}
@@ -66,3 +66,21 @@ func ErrorResponse() string {
 	}
 `
 }
+
+// TransactionNotFoundResponse is a dummy JSON response when a transaction does not exist
+func TransactionNotFoundResponse() string {
+	return `
+	{
+		"code": "ERR105",
+		"description": "Transaction not found"
+	}
+`
+}
+
+// WalletBalanceResponse is a dummy JSON response for the wallet balance
+func WalletBalanceResponse() string {
+	return `
+	{
+		"balance":"7931"
+	}`
+}
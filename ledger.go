@@ -0,0 +1,171 @@
+package mobilenig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrLedgerEntryNotFound is returned when a transaction ID does not exist in a LedgerStore
+var ErrLedgerEntryNotFound = errors.New("mobilenig: ledger entry not found")
+
+// ErrTransactionAlreadyRecorded is returned when a payment is sent with a transaction ID which is already in the
+// ledger and whose previous attempt did not fail
+var ErrTransactionAlreadyRecorded = errors.New("mobilenig: transaction ID is already recorded in the ledger")
+
+// LedgerStatus is the status of a payment in the ledger
+type LedgerStatus string
+
+const (
+	// LedgerStatusQueued is the status of a payment which has been enqueued in an Outbox but not yet sent
+	LedgerStatusQueued = LedgerStatus("QUEUED")
+
+	// LedgerStatusPending is the status of a payment which has been recorded but whose outcome is not yet known
+	LedgerStatusPending = LedgerStatus("PENDING")
+
+	// LedgerStatusSucceeded is the status of a payment which was successful
+	LedgerStatusSucceeded = LedgerStatus("SUCCEEDED")
+
+	// LedgerStatusFailed is the status of a payment which was rejected by MobileNig
+	LedgerStatusFailed = LedgerStatus("FAILED")
+
+	// LedgerStatusUnknown is the status of a payment which may or may not have reached MobileNig e.g on a network error
+	LedgerStatusUnknown = LedgerStatus("UNKNOWN")
+)
+
+func (status LedgerStatus) String() string {
+	return string(status)
+}
+
+// IsTerminal returns true when the payment outcome is final
+func (status LedgerStatus) IsTerminal() bool {
+	return status == LedgerStatusSucceeded || status == LedgerStatusFailed
+}
+
+// LedgerEntry is a payment attempt recorded in the ledger
+type LedgerEntry struct {
+	TransactionID string            `json:"trans_id"`
+	Operation     string            `json:"operation"`
+	Status        LedgerStatus      `json:"status"`
+	Request       map[string]string `json:"request"`
+	Response      json.RawMessage   `json:"response,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// clone returns a deep copy of the entry
+func (entry *LedgerEntry) clone() *LedgerEntry {
+	clone := *entry
+	clone.Request = cloneStringMap(entry.Request)
+	clone.Metadata = cloneStringMap(entry.Metadata)
+	if entry.Response != nil {
+		clone.Response = append(json.RawMessage(nil), entry.Response...)
+	}
+	return &clone
+}
+
+// LedgerStore persists payment attempts
+type LedgerStore interface {
+	// Save creates the entry or replaces the existing entry with the same TransactionID
+	Save(ctx context.Context, entry *LedgerEntry) error
+
+	// Get returns the entry with the transaction ID or ErrLedgerEntryNotFound
+	Get(ctx context.Context, transactionID string) (*LedgerEntry, error)
+
+	// List returns all entries ordered by CreatedAt
+	List(ctx context.Context) ([]*LedgerEntry, error)
+}
+
+// ledgerIndex is the in-memory index of ledger entries shared by the LedgerStore implementations
+type ledgerIndex struct {
+	mu      sync.RWMutex
+	entries map[string]*LedgerEntry
+}
+
+func newLedgerIndex() *ledgerIndex {
+	return &ledgerIndex{entries: make(map[string]*LedgerEntry)}
+}
+
+func (index *ledgerIndex) put(entry *LedgerEntry) {
+	index.entries[entry.TransactionID] = entry.clone()
+}
+
+func (index *ledgerIndex) get(transactionID string) (*LedgerEntry, error) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	entry, ok := index.entries[transactionID]
+	if !ok {
+		return nil, ErrLedgerEntryNotFound
+	}
+	return entry.clone(), nil
+}
+
+func (index *ledgerIndex) list() []*LedgerEntry {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	entries := make([]*LedgerEntry, 0, len(index.entries))
+	for _, entry := range index.entries {
+		entries = append(entries, entry.clone())
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].TransactionID < entries[j].TransactionID
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	return entries
+}
+
+// MemoryLedgerStore is a LedgerStore which keeps entries in memory
+type MemoryLedgerStore struct {
+	index *ledgerIndex
+}
+
+// NewMemoryLedgerStore creates an in-memory LedgerStore
+func NewMemoryLedgerStore() *MemoryLedgerStore {
+	return &MemoryLedgerStore{index: newLedgerIndex()}
+}
+
+// Save creates or replaces the entry
+func (store *MemoryLedgerStore) Save(_ context.Context, entry *LedgerEntry) error {
+	if entry == nil || entry.TransactionID == "" {
+		return errors.New("mobilenig: ledger entry must have a transaction ID")
+	}
+
+	store.index.mu.Lock()
+	defer store.index.mu.Unlock()
+
+	store.index.put(entry)
+	return nil
+}
+
+// Get returns the entry with the transaction ID
+func (store *MemoryLedgerStore) Get(_ context.Context, transactionID string) (*LedgerEntry, error) {
+	return store.index.get(transactionID)
+}
+
+// List returns all entries ordered by CreatedAt
+func (store *MemoryLedgerStore) List(_ context.Context) ([]*LedgerEntry, error) {
+	return store.index.list(), nil
+}
+
+func cloneStringMap(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+
+	clone := make(map[string]string, len(values))
+	for key, value := range values {
+		clone[key] = value
+	}
+	return clone
+}
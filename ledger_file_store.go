@@ -0,0 +1,156 @@
+package mobilenig
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+// FileLedgerStore is a LedgerStore which appends every change as a JSON line to a file.
+// The latest line for a transaction ID wins when the file is loaded. Several processes can append to the same file,
+// every line is written with a single write and Reload reads the lines appended by the other processes.
+type FileLedgerStore struct {
+	mu         sync.Mutex
+	file       *os.File
+	index      *ledgerIndex
+	readOffset int64
+}
+
+// NewFileLedgerStore opens or creates the append-only ledger file at path
+func NewFileLedgerStore(path string) (*FileLedgerStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &FileLedgerStore{file: file, index: newLedgerIndex()}
+	if err = store.read(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// read adds the lines after readOffset to the in-memory index.
+// The file is never truncated: a partially written last line may be a write of another process which is in progress,
+// so it is left for the next read. A complete line which is not valid JSON is a write which was torn by a crash. It was
+// never acknowledged by Save, so it is skipped.
+func (store *FileLedgerStore) read() error {
+	reader := bufio.NewReader(io.NewSectionReader(store.file, store.readOffset, math.MaxInt64-store.readOffset))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		store.readOffset += int64(len(line))
+
+		entry := new(LedgerEntry)
+		if line = bytes.TrimSpace(line); len(line) == 0 || json.Unmarshal(line, entry) != nil || entry.TransactionID == "" {
+			continue
+		}
+
+		store.index.mu.Lock()
+		store.index.put(entry)
+		store.index.mu.Unlock()
+	}
+}
+
+// Reload reads the lines which have been appended to the ledger file since it was last read, e.g. by another process.
+// A partially written last line is left for the next Reload.
+func (store *FileLedgerStore) Reload(_ context.Context) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.read()
+}
+
+// Save appends the entry to the ledger file
+func (store *FileLedgerStore) Save(_ context.Context, entry *LedgerEntry) error {
+	if entry == nil || entry.TransactionID == "" {
+		return errors.New("mobilenig: ledger entry must have a transaction ID")
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// The entry must start on a new line when the file ends with a partially written line
+	complete, err := store.endsWithNewline()
+	if err != nil {
+		return err
+	}
+	if !complete {
+		line = append([]byte{'\n'}, line...)
+	}
+
+	if _, err = store.file.Write(line); err != nil {
+		return err
+	}
+
+	if err = store.file.Sync(); err != nil {
+		return err
+	}
+
+	store.index.mu.Lock()
+	store.index.put(entry)
+	store.index.mu.Unlock()
+
+	// The line doesn't need to be read again when nothing was appended by another process since the last read
+	end, err := store.file.Seek(0, io.SeekCurrent)
+	if err == nil && end-int64(len(line)) == store.readOffset {
+		store.readOffset = end
+	}
+
+	return nil
+}
+
+// endsWithNewline returns true when the ledger file is empty or its last byte is a newline
+func (store *FileLedgerStore) endsWithNewline() (bool, error) {
+	info, err := store.file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if info.Size() == 0 {
+		return true, nil
+	}
+
+	last := make([]byte, 1)
+	if _, err = store.file.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] == '\n', nil
+}
+
+// Get returns the entry with the transaction ID
+func (store *FileLedgerStore) Get(_ context.Context, transactionID string) (*LedgerEntry, error) {
+	return store.index.get(transactionID)
+}
+
+// List returns all entries ordered by CreatedAt
+func (store *FileLedgerStore) List(_ context.Context) ([]*LedgerEntry, error) {
+	return store.index.list(), nil
+}
+
+// Close closes the ledger file
+func (store *FileLedgerStore) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.file.Close()
+}
@@ -0,0 +1,213 @@
+package mobilenig
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLedgerEntry(transactionID string, status LedgerStatus, createdAt time.Time) *LedgerEntry {
+	return &LedgerEntry{
+		TransactionID: transactionID,
+		Operation:     "PayDStv",
+		Status:        status,
+		Request:       map[string]string{"trans_id": transactionID, "price": "790"},
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+	}
+}
+
+func TestMemoryLedgerStore(t *testing.T) {
+	t.Run("it saves, replaces and lists entries", func(t *testing.T) {
+		// Arrange
+		store := NewMemoryLedgerStore()
+		now := time.Now()
+
+		// Act
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("2", LedgerStatusPending, now)))
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("1", LedgerStatusPending, now.Add(-time.Minute))))
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("2", LedgerStatusSucceeded, now)))
+
+		// Assert
+		entry, err := store.Get(context.Background(), "2")
+		assert.NoError(t, err)
+		assert.Equal(t, LedgerStatusSucceeded, entry.Status)
+
+		entries, err := store.List(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "1", entries[0].TransactionID)
+		assert.Equal(t, "2", entries[1].TransactionID)
+	})
+
+	t.Run("it returns ErrLedgerEntryNotFound for a missing entry", func(t *testing.T) {
+		// Arrange
+		store := NewMemoryLedgerStore()
+
+		// Act
+		_, err := store.Get(context.Background(), "missing")
+
+		// Assert
+		assert.Equal(t, ErrLedgerEntryNotFound, err)
+	})
+
+	t.Run("entries cannot be modified outside the store", func(t *testing.T) {
+		// Arrange
+		store := NewMemoryLedgerStore()
+		entry := newTestLedgerEntry("1", LedgerStatusPending, time.Now())
+		assert.NoError(t, store.Save(context.Background(), entry))
+
+		// Act
+		entry.Request["price"] = "1"
+		saved, _ := store.Get(context.Background(), "1")
+		saved.Status = LedgerStatusFailed
+
+		// Assert
+		saved, _ = store.Get(context.Background(), "1")
+		assert.Equal(t, "790", saved.Request["price"])
+		assert.Equal(t, LedgerStatusPending, saved.Status)
+	})
+}
+
+func TestFileLedgerStore(t *testing.T) {
+	t.Run("entries are loaded when the file is reopened", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+		store, err := NewFileLedgerStore(path)
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("1", LedgerStatusPending, time.Now())))
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("1", LedgerStatusSucceeded, time.Now())))
+		assert.NoError(t, store.Close())
+
+		// Act
+		store, err = NewFileLedgerStore(path)
+
+		// Assert
+		assert.NoError(t, err)
+		entry, err := store.Get(context.Background(), "1")
+		assert.NoError(t, err)
+		assert.Equal(t, LedgerStatusSucceeded, entry.Status)
+
+		contents, _ := ioutil.ReadFile(path)
+		assert.Equal(t, 2, len(splitLines(contents)))
+
+		assert.NoError(t, store.Close())
+	})
+
+	t.Run("a partially written last line is not truncated", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+		store, err := NewFileLedgerStore(path)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("1", LedgerStatusPending, time.Now())))
+		assert.NoError(t, store.Close())
+
+		file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		_, _ = file.WriteString(`{"trans_id":"2","stat`)
+		_ = file.Close()
+
+		// Act
+		store, err = NewFileLedgerStore(path)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("3", LedgerStatusPending, time.Now())))
+		assert.NoError(t, store.Close())
+		store, err = NewFileLedgerStore(path)
+
+		// Assert
+		assert.NoError(t, err)
+		entries, _ := store.List(context.Background())
+		assert.Len(t, entries, 2)
+
+		contents, _ := ioutil.ReadFile(path)
+		assert.Contains(t, string(contents), "{\"trans_id\":\"2\",\"stat\n")
+
+		assert.NoError(t, store.Close())
+	})
+
+	t.Run("a line which is being written by another process is read once it is complete", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+		line, _ := json.Marshal(newTestLedgerEntry("1", LedgerStatusPending, time.Now()))
+
+		file, _ := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		_, _ = file.Write(line[:10])
+
+		store, err := NewFileLedgerStore(path)
+		assert.NoError(t, err)
+
+		// Act
+		_, _ = file.Write(append(line[10:], '\n'))
+		_ = file.Close()
+		err = store.Reload(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		entry, err := store.Get(context.Background(), "1")
+		assert.NoError(t, err)
+		assert.Equal(t, LedgerStatusPending, entry.Status)
+
+		assert.NoError(t, store.Close())
+	})
+
+	t.Run("entries saved by the store are not read again", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+		store, err := NewFileLedgerStore(path)
+		assert.NoError(t, err)
+
+		// Act
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("1", LedgerStatusPending, time.Now())))
+		assert.NoError(t, store.Save(context.Background(), newTestLedgerEntry("2", LedgerStatusPending, time.Now())))
+
+		// Assert
+		info, _ := os.Stat(path)
+		assert.Equal(t, info.Size(), store.readOffset)
+
+		assert.NoError(t, store.Close())
+	})
+
+	t.Run("entries appended by another process are reloaded", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+		reader, err := NewFileLedgerStore(path)
+		assert.NoError(t, err)
+		writer, err := NewFileLedgerStore(path)
+		assert.NoError(t, err)
+
+		assert.NoError(t, writer.Save(context.Background(), newTestLedgerEntry("1", LedgerStatusPending, time.Now())))
+		file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		_, _ = file.WriteString(`{"trans_id":"2","stat`)
+		_ = file.Close()
+
+		// Act
+		err = reader.Reload(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		entries, _ := reader.List(context.Background())
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "1", entries[0].TransactionID)
+
+		assert.NoError(t, reader.Close())
+		assert.NoError(t, writer.Close())
+	})
+}
+
+func splitLines(contents []byte) []string {
+	var lines []string
+	start := 0
+	for i, b := range contents {
+		if b == '\n' {
+			lines = append(lines, string(contents[start:i]))
+			start = i + 1
+		}
+	}
+	return lines
+}
@@ -0,0 +1,45 @@
+package mobilenig
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvAllowLivePayments is the environment variable which unlocks payments in the LiveEnvironment when it is "true"
+const EnvAllowLivePayments = "MOBILENIG_ALLOW_LIVE_PAYMENTS"
+
+// ErrLivePaymentsLocked is returned when a payment is made in the LiveEnvironment without unlocking live payments
+var ErrLivePaymentsLocked = errors.New("mobilenig: live payments are locked")
+
+// runningInGoTest returns true when the process is a test binary built by `go test`
+var runningInGoTest = func() bool {
+	return flag.Lookup("test.v") != nil || strings.HasSuffix(os.Args[0], ".test")
+}
+
+// checkLivePaymentLock refuses money-moving calls in the LiveEnvironment unless live payments have been unlocked with
+// WithLivePaymentsUnlocked or the MOBILENIG_ALLOW_LIVE_PAYMENTS environment variable. Only test hosts are exempt.
+// Live payments are always refused when running inside `go test`.
+func (client *Client) checkLivePaymentLock(endpoint endpoint) error {
+	if !endpoint.moneyMoving || client.environment != LiveEnvironment || client.isTestBaseURL() {
+		return nil
+	}
+
+	if runningInGoTest() {
+		return fmt.Errorf("%w: [%s] cannot be called in the [%s] environment inside go test", ErrLivePaymentsLocked, endpoint.operation, LiveEnvironment)
+	}
+
+	if !client.livePaymentsUnlocked && os.Getenv(EnvAllowLivePayments) != "true" {
+		return fmt.Errorf(
+			"%w: use WithLivePaymentsUnlocked() or set %s=true to call [%s] in the [%s] environment",
+			ErrLivePaymentsLocked,
+			EnvAllowLivePayments,
+			endpoint.operation,
+			LiveEnvironment,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripperFunc is an http.RoundTripper which calls the function
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestClient_LivePaymentLock(t *testing.T) {
+	// Arrange
+	requests := 0
+	httpClient := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return nil, errors.New("network is disabled")
+	})}
+
+	defer func(detect func() bool) { runningInGoTest = detect }(runningInGoTest)
+	runningInGoTest = func() bool { return false }
+
+	t.Run("live payments are locked by default", func(t *testing.T) {
+		// Act
+		_, _, err := New(WithHTTPClient(httpClient)).Bills.PayDStv(context.Background(), &PayDstvOptions{})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrLivePaymentsLocked))
+		assert.Equal(t, 0, requests)
+	})
+
+	t.Run("live payments can be unlocked with an option", func(t *testing.T) {
+		// Act
+		_, _, err := New(WithHTTPClient(httpClient), WithLivePaymentsUnlocked()).Bills.PayDStv(context.Background(), &PayDstvOptions{})
+
+		// Assert
+		assert.False(t, errors.Is(err, ErrLivePaymentsLocked))
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("live payments can be unlocked with an environment variable", func(t *testing.T) {
+		// Arrange
+		_ = os.Setenv(EnvAllowLivePayments, "true")
+		defer func() { _ = os.Unsetenv(EnvAllowLivePayments) }()
+
+		// Act
+		_, _, err := New(WithHTTPClient(httpClient)).Bills.PayDStv(context.Background(), &PayDstvOptions{})
+
+		// Assert
+		assert.False(t, errors.Is(err, ErrLivePaymentsLocked))
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("lookups are not locked", func(t *testing.T) {
+		// Act
+		_, _, err := New(WithHTTPClient(httpClient)).Bills.QueryDStv(context.Background(), "122790223")
+
+		// Assert
+		assert.False(t, errors.Is(err, ErrLivePaymentsLocked))
+		assert.Equal(t, 3, requests)
+	})
+
+	t.Run("live payments are always locked inside go test", func(t *testing.T) {
+		// Arrange
+		runningInGoTest = func() bool { return true }
+
+		// Act
+		_, _, err := New(WithHTTPClient(httpClient), WithLivePaymentsUnlocked()).Bills.PayDStv(context.Background(), &PayDstvOptions{})
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrLivePaymentsLocked))
+		assert.Equal(t, 3, requests)
+	})
+
+	t.Run("payments to a loopback base URL are not locked", func(t *testing.T) {
+		// Arrange
+		baseURL, _ := url.Parse("http://127.0.0.1:8080")
+
+		// Act
+		_, _, err := New(WithHTTPClient(httpClient), WithBaseURL(baseURL)).Bills.PayDStv(context.Background(), &PayDstvOptions{})
+
+		// Assert
+		assert.False(t, errors.Is(err, ErrLivePaymentsLocked))
+		assert.Equal(t, 4, requests)
+	})
+
+	t.Run("payments to any other base URL are locked", func(t *testing.T) {
+		for _, rawURL := range []string{"https://api.mobilenig.com", "https://41.203.10.5", "https://mobilenig-proxy.internal"} {
+			// Arrange
+			baseURL, _ := url.Parse(rawURL)
+
+			// Act
+			_, _, err := New(WithHTTPClient(httpClient), WithBaseURL(baseURL)).Bills.PayDStv(context.Background(), &PayDstvOptions{})
+
+			// Assert
+			assert.True(t, errors.Is(err, ErrLivePaymentsLocked), rawURL)
+		}
+		assert.Equal(t, 4, requests)
+	})
+
+	t.Run("payments to an allowed test host are not locked", func(t *testing.T) {
+		// Arrange
+		baseURL, _ := url.Parse("http://fake-mobilenig:8080")
+
+		// Act
+		_, _, err := New(WithHTTPClient(httpClient), WithBaseURL(baseURL), WithTestHosts("Fake-MobileNig")).Bills.PayDStv(context.Background(), &PayDstvOptions{})
+
+		// Assert
+		assert.False(t, errors.Is(err, ErrLivePaymentsLocked))
+		assert.Equal(t, 5, requests)
+	})
+}
+
+func TestRunningInGoTest(t *testing.T) {
+	assert.True(t, runningInGoTest())
+}
@@ -0,0 +1,368 @@
+package mobilenigtest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrInteractionNotFound is returned by a Replayer when the cassette has no interaction for a request
+var ErrInteractionNotFound = errors.New("mobilenigtest: the cassette has no interaction for the request")
+
+// EnvCassetteKey is the environment variable with the key used to hash smartcard numbers in a cassette.
+// The same key must be used to record and to replay a cassette. A random key is used for each recording when it is
+// not set.
+const EnvCassetteKey = "MOBILENIG_CASSETTE_KEY"
+
+// redacted replaces the secrets in a cassette
+const redacted = "REDACTED"
+
+// redactedParams are the query parameters which are redacted in a cassette
+var redactedParams = []string{"api_key", "username"}
+
+// hashedParams are the query parameters and response fields which are replaced with a keyed hash in a cassette so
+// that requests for different smartcards can still be told apart when they are replayed
+var hashedParams = []string{"smartno", "number"}
+
+// hashValue returns the HMAC-SHA256 of the value keyed with key
+func hashValue(key string, value string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	_, _ = mac.Write([]byte(value))
+	return "hmac-" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomKey returns a random key which is only known by a single Recorder
+func randomKey() string {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("mobilenigtest: cannot generate a cassette key: %v", err))
+	}
+	return hex.EncodeToString(key)
+}
+
+// hashBody replaces the smartcard numbers in a JSON response body with a keyed hash.
+// The body is returned unchanged when it is not JSON or has no smartcard numbers.
+func hashBody(key string, body string) string {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if decoder.Decode(&value) != nil || decoder.More() {
+		return body
+	}
+
+	if !hashFields(key, value) {
+		return body
+	}
+
+	contents, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return string(contents)
+}
+
+// hashFields replaces the values of the hashedParams fields in the JSON value and reports whether any was replaced
+func hashFields(key string, value interface{}) bool {
+	hashed := false
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range value {
+			if containsString(hashedParams, field) {
+				switch scalar := fieldValue.(type) {
+				case string:
+					if scalar != "" && !strings.HasPrefix(scalar, "hmac-") {
+						value[field], hashed = hashValue(key, scalar), true
+					}
+					continue
+				case json.Number:
+					value[field], hashed = hashValue(key, scalar.String()), true
+					continue
+				}
+			}
+			if hashFields(key, fieldValue) {
+				hashed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if hashFields(key, item) {
+				hashed = true
+			}
+		}
+	}
+	return hashed
+}
+
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Cassette is a list of recorded HTTP interactions
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+
+	// RandomKey reports that the smartcard numbers were hashed with a random key because MOBILENIG_CASSETTE_KEY was not
+	// set when the cassette was recorded. The smartcard numbers of such a cassette are not used to match requests.
+	RandomKey bool `json:"random_key,omitempty"`
+}
+
+// Interaction is a request and the response which was received for it
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request in a cassette. Query is normalized with the secrets redacted and the smartcard numbers
+// replaced with a keyed hash.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query"`
+}
+
+// RecordedResponse is a response in a cassette
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// LoadCassette reads a cassette from a JSON file
+func LoadCassette(path string) (*Cassette, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cassette := new(Cassette)
+	if err = json.Unmarshal(contents, cassette); err != nil {
+		return nil, fmt.Errorf("mobilenigtest: invalid cassette [%s]: %w", path, err)
+	}
+	return cassette, nil
+}
+
+// Save writes the cassette to a JSON file
+func (cassette *Cassette) Save(path string) error {
+	contents, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(contents, '\n'), 0o600)
+}
+
+// normalizeQuery redacts the secrets, hashes the smartcard numbers with cassetteKey, removes the ignored params and sorts the
+// query. Values which are already hashed are kept so that a recorded query is normalized to itself.
+func normalizeQuery(cassetteKey string, query url.Values, ignoredParams ...string) string {
+	normalized := make(url.Values, len(query))
+	for key, values := range query {
+		normalized[key] = append([]string(nil), values...)
+	}
+
+	for _, key := range redactedParams {
+		if _, ok := normalized[key]; ok {
+			normalized.Set(key, redacted)
+		}
+	}
+
+	for _, key := range hashedParams {
+		if value := normalized.Get(key); value != "" && !strings.HasPrefix(value, "hmac-") {
+			normalized.Set(key, hashValue(cassetteKey, value))
+		}
+	}
+
+	for _, key := range ignoredParams {
+		normalized.Del(key)
+	}
+
+	return normalized.Encode()
+}
+
+// Recorder is an http.RoundTripper which records the requests it sends and their responses in a Cassette.
+// The api_key and username are redacted from the requests, and smartcard numbers are replaced with a keyed hash in the
+// requests and the responses.
+type Recorder struct {
+	transport http.RoundTripper
+	key       string
+	randomKey bool
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a Recorder which sends requests with transport.
+// http.DefaultTransport is used when transport is nil. Smartcard numbers are hashed with MOBILENIG_CASSETTE_KEY, or
+// with a random key which is never saved when it is not set.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	recorder := &Recorder{transport: transport, key: os.Getenv(EnvCassetteKey)}
+	if recorder.key == "" {
+		recorder.key, recorder.randomKey = randomKey(), true
+	}
+	return recorder
+}
+
+// RoundTrip sends the request and records the interaction
+func (recorder *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := recorder.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	recordedBody := hashBody(recorder.key, string(body))
+	for _, key := range hashedParams {
+		if value := req.URL.Query().Get(key); value != "" {
+			recordedBody = strings.ReplaceAll(recordedBody, value, hashValue(recorder.key, value))
+		}
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	recorder.cassette.Interactions = append(recorder.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  normalizeQuery(recorder.key, req.URL.Query()),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       recordedBody,
+		},
+	})
+
+	return resp, nil
+}
+
+// Cassette returns a copy of the interactions recorded so far
+func (recorder *Recorder) Cassette() *Cassette {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	return &Cassette{
+		Interactions: append([]Interaction(nil), recorder.cassette.Interactions...),
+		RandomKey:    recorder.randomKey,
+	}
+}
+
+// Save writes the recorded interactions to a cassette file
+func (recorder *Recorder) Save(path string) error {
+	return recorder.Cassette().Save(path)
+}
+
+// Replayer is an http.RoundTripper which serves the responses in a Cassette without any network call.
+// Requests are matched on the method, path and normalized query. Interactions with the same request are served in the
+// order in which they were recorded, and the last one is served again once they have all been used.
+// The smartcard numbers are hashed with MOBILENIG_CASSETTE_KEY, so it must be the key used to record the cassette.
+type Replayer struct {
+	cassette      *Cassette
+	key           string
+	ignoredParams []string
+
+	mu   sync.Mutex
+	used map[int]bool
+}
+
+// NewReplayer creates a Replayer for the cassette file at path.
+// The ignoredParams are not used to match requests e.g "trans_id" when transaction IDs are generated.
+func NewReplayer(path string, ignoredParams ...string) (*Replayer, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCassetteReplayer(cassette, ignoredParams...), nil
+}
+
+// NewCassetteReplayer creates a Replayer for the cassette
+func NewCassetteReplayer(cassette *Cassette, ignoredParams ...string) *Replayer {
+	if cassette.RandomKey {
+		ignoredParams = append(append([]string(nil), ignoredParams...), hashedParams...)
+	}
+
+	return &Replayer{
+		cassette:      cassette,
+		key:           os.Getenv(EnvCassetteKey),
+		ignoredParams: ignoredParams,
+		used:          make(map[int]bool),
+	}
+}
+
+// RoundTrip returns the recorded response for the request
+func (replayer *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	interaction, err := replayer.match(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(interaction.Response.StatusCode),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.Response.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewBufferString(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// match returns the first unused interaction for the request, or the last used one
+func (replayer *Replayer) match(req *http.Request) (*Interaction, error) {
+	query := normalizeQuery(replayer.key, req.URL.Query(), replayer.ignoredParams...)
+
+	replayer.mu.Lock()
+	defer replayer.mu.Unlock()
+
+	last := -1
+	for i, interaction := range replayer.cassette.Interactions {
+		recorded := interaction.Request
+		if recorded.Method != req.Method || recorded.Path != req.URL.Path {
+			continue
+		}
+
+		recordedQuery, err := url.ParseQuery(recorded.Query)
+		if err != nil || normalizeQuery(replayer.key, recordedQuery, replayer.ignoredParams...) != query {
+			continue
+		}
+
+		if !replayer.used[i] {
+			replayer.used[i] = true
+			return &replayer.cassette.Interactions[i], nil
+		}
+		last = i
+	}
+
+	if last >= 0 {
+		return &replayer.cassette.Interactions[last], nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s?%s", ErrInteractionNotFound, req.Method, req.URL.Path, query)
+}
@@ -0,0 +1,138 @@
+package mobilenigtest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder_Replayer(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	server := newTestServer()
+	recorder := NewRecorder(nil)
+	client := server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: recorder}))
+
+	_, _, err := client.Bills.CheckDStvUser(context.Background(), "4131953321")
+	assert.NoError(t, err)
+	_, _, err = client.Bills.PayDStv(context.Background(), newTestPayment("1", "2000"))
+	assert.NoError(t, err)
+	assert.NoError(t, recorder.Save(path))
+	server.Close()
+
+	t.Run("secrets and smartcard numbers are redacted", func(t *testing.T) {
+		// Act
+		contents, err := ioutil.ReadFile(path)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NotContains(t, string(contents), "4131953321")
+		assert.NotContains(t, string(contents), "mobilenigtest")
+	})
+
+	t.Run("interactions are replayed without the network", func(t *testing.T) {
+		// Arrange
+		replayer, err := NewReplayer(path, "trans_id")
+		assert.NoError(t, err)
+		client := server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: replayer}))
+
+		// Act
+		user, _, userErr := client.Bills.CheckDStvUser(context.Background(), "4131953321")
+		transaction, _, payErr := client.Bills.PayDStv(context.Background(), newTestPayment("2", "2000"))
+		_, _, queryErr := client.Bills.QueryDStv(context.Background(), "1")
+
+		// Assert
+		assert.NoError(t, userErr)
+		assert.Equal(t, int64(275953782), user.Details.CustomerNumber)
+
+		assert.NoError(t, payErr)
+		assert.Equal(t, "3000", transaction.Details.Balance)
+
+		assert.True(t, errors.Is(queryErr, ErrInteractionNotFound))
+	})
+}
+
+func TestRecorder_Replayer_DifferentSmartcards(t *testing.T) {
+	// Setup
+	_ = os.Setenv(EnvCassetteKey, "cassette-test-key")
+
+	// Arrange
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	server := NewServer(WithSmartcards(
+		Smartcard{Number: "4131953321", CustomerNumber: 275953782, FirstName: "ESU"},
+		Smartcard{Number: "7027914329", CustomerNumber: 100200300, FirstName: "ADA"},
+	))
+	recorder := NewRecorder(nil)
+	client := server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: recorder}))
+
+	_, _, err := client.Bills.CheckDStvUser(context.Background(), "4131953321")
+	assert.NoError(t, err)
+	_, _, err = client.Bills.CheckDStvUser(context.Background(), "7027914329")
+	assert.NoError(t, err)
+	assert.NoError(t, recorder.Save(path))
+	server.Close()
+
+	replayer, err := NewReplayer(path)
+	assert.NoError(t, err)
+	client = server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: replayer}))
+
+	// Act
+	second, _, secondErr := client.Bills.CheckDStvUser(context.Background(), "7027914329")
+	first, _, firstErr := client.Bills.CheckDStvUser(context.Background(), "4131953321")
+	_, _, unknownErr := client.Bills.CheckDStvUser(context.Background(), "1111111111")
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.Equal(t, "ESU", first.Details.Firstname)
+
+	assert.NoError(t, secondErr)
+	assert.Equal(t, "ADA", second.Details.Firstname)
+
+	assert.True(t, errors.Is(unknownErr, ErrInteractionNotFound))
+
+	contents, _ := ioutil.ReadFile(path)
+	assert.NotContains(t, string(contents), "7027914329")
+	assert.Contains(t, string(contents), hashValue("cassette-test-key", "7027914329"))
+
+	// Teardown
+	_ = os.Unsetenv(EnvCassetteKey)
+}
+
+func TestRecorder_RandomKey(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	server := newTestServer()
+	recorder := NewRecorder(nil)
+	client := server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: recorder}))
+
+	_, _, err := client.Bills.PayDStv(context.Background(), newTestPayment("1", "2000"))
+	assert.NoError(t, err)
+	_, _, err = client.Bills.QueryDStv(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.NoError(t, recorder.Save(path))
+	server.Close()
+
+	// Act
+	contents, readErr := ioutil.ReadFile(path)
+	replayer, replayerErr := NewReplayer(path)
+	client = server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: replayer}))
+	transaction, _, queryErr := client.Bills.QueryDStv(context.Background(), "1")
+
+	// Assert
+	assert.NoError(t, readErr)
+	assert.NotContains(t, string(contents), "4131953321")
+	assert.NotContains(t, string(contents), hashValue("", "4131953321"))
+	assert.Contains(t, string(contents), `"random_key": true`)
+
+	assert.NoError(t, replayerErr)
+	assert.NoError(t, queryErr)
+	assert.True(t, strings.HasPrefix(transaction.Details.SmartcardNumber, "hmac-"))
+}
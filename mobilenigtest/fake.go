@@ -0,0 +1,137 @@
+package mobilenigtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// ErrNotProgrammed is returned by a fake when no response has been programmed for a method
+var ErrNotProgrammed = errors.New("mobilenigtest: no response has been programmed for the method")
+
+// Call is a call recorded by a fake
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// calls records the calls made to a fake
+type calls struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (recorded *calls) record(method string, args ...interface{}) {
+	recorded.mu.Lock()
+	defer recorded.mu.Unlock()
+
+	recorded.calls = append(recorded.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns the calls made to the fake in order
+func (recorded *calls) Calls() []Call {
+	recorded.mu.Lock()
+	defer recorded.mu.Unlock()
+
+	return append([]Call(nil), recorded.calls...)
+}
+
+// CallsTo returns the calls made to a method of the fake in order
+func (recorded *calls) CallsTo(method string) []Call {
+	var matching []Call
+	for _, call := range recorded.Calls() {
+		if call.Method == method {
+			matching = append(matching, call)
+		}
+	}
+	return matching
+}
+
+// FakeBills is a mobilenig.BillsAPI which records its calls and returns the responses of the programmed functions.
+// ErrNotProgrammed is returned when the function of a method is nil.
+type FakeBills struct {
+	calls
+
+	CheckDStvUserFunc  func(ctx context.Context, smartcardNumber string) (*mobilenig.DStvUser, *mobilenig.Response, error)
+	GetDStvPackageFunc func(ctx context.Context, customerNumber int64) (*string, *mobilenig.Response, error)
+	PayDStvFunc        func(ctx context.Context, options *mobilenig.PayDstvOptions) (*mobilenig.DStvTransaction, *mobilenig.Response, error)
+	QueryDStvFunc      func(ctx context.Context, transactionID string) (*mobilenig.DStvTransaction, *mobilenig.Response, error)
+}
+
+var _ mobilenig.BillsAPI = (*FakeBills)(nil)
+
+// CheckDStvUser records the call and calls CheckDStvUserFunc
+func (fake *FakeBills) CheckDStvUser(ctx context.Context, smartcardNumber string) (*mobilenig.DStvUser, *mobilenig.Response, error) {
+	fake.record("CheckDStvUser", smartcardNumber)
+	if fake.CheckDStvUserFunc == nil {
+		return nil, nil, ErrNotProgrammed
+	}
+	return fake.CheckDStvUserFunc(ctx, smartcardNumber)
+}
+
+// GetDStvPackage records the call and calls GetDStvPackageFunc
+func (fake *FakeBills) GetDStvPackage(ctx context.Context, customerNumber int64) (*string, *mobilenig.Response, error) {
+	fake.record("GetDStvPackage", customerNumber)
+	if fake.GetDStvPackageFunc == nil {
+		return nil, nil, ErrNotProgrammed
+	}
+	return fake.GetDStvPackageFunc(ctx, customerNumber)
+}
+
+// PayDStv records the call with a copy of the options and calls PayDStvFunc
+func (fake *FakeBills) PayDStv(ctx context.Context, options *mobilenig.PayDstvOptions) (*mobilenig.DStvTransaction, *mobilenig.Response, error) {
+	var recorded interface{}
+	if options != nil {
+		clone := *options
+		recorded = &clone
+	}
+	fake.record("PayDStv", recorded)
+
+	if fake.PayDStvFunc == nil {
+		return nil, nil, ErrNotProgrammed
+	}
+	return fake.PayDStvFunc(ctx, options)
+}
+
+// QueryDStv records the call and calls QueryDStvFunc
+func (fake *FakeBills) QueryDStv(ctx context.Context, transactionID string) (*mobilenig.DStvTransaction, *mobilenig.Response, error) {
+	fake.record("QueryDStv", transactionID)
+	if fake.QueryDStvFunc == nil {
+		return nil, nil, ErrNotProgrammed
+	}
+	return fake.QueryDStvFunc(ctx, transactionID)
+}
+
+// FakeWallet is a mobilenig.WalletAPI which records its calls and returns the responses of GetBalanceFunc.
+// ErrNotProgrammed is returned when GetBalanceFunc is nil.
+type FakeWallet struct {
+	calls
+
+	GetBalanceFunc func(ctx context.Context) (*mobilenig.WalletBalance, *mobilenig.Response, error)
+}
+
+var _ mobilenig.WalletAPI = (*FakeWallet)(nil)
+
+// GetBalance records the call and calls GetBalanceFunc
+func (fake *FakeWallet) GetBalance(ctx context.Context) (*mobilenig.WalletBalance, *mobilenig.Response, error) {
+	fake.record("GetBalance")
+	if fake.GetBalanceFunc == nil {
+		return nil, nil, ErrNotProgrammed
+	}
+	return fake.GetBalanceFunc(ctx)
+}
+
+// PayDStvSucceeds returns a PayDStvFunc which returns a SUCCESSFUL transaction for every payment
+func PayDStvSucceeds() func(ctx context.Context, options *mobilenig.PayDstvOptions) (*mobilenig.DStvTransaction, *mobilenig.Response, error) {
+	return func(_ context.Context, options *mobilenig.PayDstvOptions) (*mobilenig.DStvTransaction, *mobilenig.Response, error) {
+		transaction := &mobilenig.DStvTransaction{TransactionID: options.TransactionID}
+		transaction.Details.Service = "DSTV"
+		transaction.Details.Package = string(options.ProductCode)
+		transaction.Details.SmartcardNumber = options.SmartcardNumber
+		transaction.Details.Price = options.Price
+		transaction.Details.Status = "SUCCESSFUL"
+		return transaction, &mobilenig.Response{}, nil
+	}
+}
@@ -0,0 +1,42 @@
+package mobilenigtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeBills(t *testing.T) {
+	t.Run("calls are recorded and the programmed responses are returned", func(t *testing.T) {
+		// Arrange
+		var bills mobilenig.BillsAPI = &FakeBills{PayDStvFunc: PayDStvSucceeds()}
+		options := newTestPayment("1", "2000")
+
+		// Act
+		transaction, _, err := bills.PayDStv(context.Background(), options)
+		options.Price = "3000"
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "SUCCESSFUL", transaction.Details.Status)
+
+		calls := bills.(*FakeBills).CallsTo("PayDStv")
+		assert.Equal(t, 1, len(calls))
+		assert.Equal(t, "2000", calls[0].Args[0].(*mobilenig.PayDstvOptions).Price)
+	})
+
+	t.Run("methods without a programmed response fail", func(t *testing.T) {
+		// Arrange
+		fake := new(FakeBills)
+
+		// Act
+		_, _, err := fake.QueryDStv(context.Background(), "1")
+
+		// Assert
+		assert.True(t, errors.Is(err, ErrNotProgrammed))
+		assert.Equal(t, []Call{{Method: "QueryDStv", Args: []interface{}{"1"}}}, fake.Calls())
+	})
+}
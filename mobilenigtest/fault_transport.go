@@ -0,0 +1,206 @@
+package mobilenigtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+// timeoutError is a net.Error which reports a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "mobilenigtest: injected timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// ErrTimeout is the error returned by TimeoutBeforeSending and TimeoutAfterSending. It implements net.Error.
+var ErrTimeout error = timeoutError{}
+
+// Fault is the behaviour injected in a request by a FaultTransport
+type Fault struct {
+	// Delay is how long the transport waits before responding. The wait stops when the request context is done.
+	Delay time.Duration
+
+	// Forward sends the request to the underlying transport before the fault is applied
+	Forward bool
+
+	// Err is returned instead of a response when it is not nil
+	Err error
+
+	// StatusCode, Header and Body replace the response when StatusCode is not 0
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// TimeoutBeforeSending fails with ErrTimeout without sending the request
+func TimeoutBeforeSending() Fault {
+	return Fault{Err: ErrTimeout}
+}
+
+// TimeoutAfterSending sends the request and fails with ErrTimeout, so the outcome is unknown to the client
+func TimeoutAfterSending() Fault {
+	return Fault{Forward: true, Err: ErrTimeout}
+}
+
+// SlowResponse sends the request and returns the response after the delay
+func SlowResponse(delay time.Duration) Fault {
+	return Fault{Forward: true, Delay: delay}
+}
+
+// HTMLResponse returns an HTML error page with the status code e.g. from a proxy in front of the API
+func HTMLResponse(statusCode int) Fault {
+	return Fault{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       "<html><body><h1>" + http.StatusText(statusCode) + "</h1></body></html>",
+	}
+}
+
+// APIError returns a MobileNig error response without sending the request
+func APIError(code string, description string) Fault {
+	body, _ := json.Marshal(mobilenig.ErrorResponse{Code: code, Description: description})
+	return Fault{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       string(body),
+	}
+}
+
+// DuplicateTransactionID returns the error of a payment with a trans_id which has already been used
+func DuplicateTransactionID() Fault {
+	return APIError(ErrorCodeDuplicateTransaction, "Duplicate transaction ID")
+}
+
+type scriptedFault struct {
+	operation mobilenig.Operation
+	call      int
+	fault     Fault
+}
+
+// FaultTransport is an http.RoundTripper which injects scripted faults in the requests for MobileNig operations.
+// Use it with mobilenig.WithHTTPClient(&http.Client{Transport: transport}).
+type FaultTransport struct {
+	transport http.RoundTripper
+
+	mu     sync.Mutex
+	faults []scriptedFault
+	calls  map[mobilenig.Operation]int
+}
+
+// NewFaultTransport creates a FaultTransport which sends requests with transport.
+// http.DefaultTransport is used when transport is nil.
+func NewFaultTransport(transport http.RoundTripper) *FaultTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &FaultTransport{transport: transport, calls: make(map[mobilenig.Operation]int)}
+}
+
+// Inject applies the fault to the nth request for the operation, starting from 1. The fault is applied to every
+// request for the operation when call is 0. The first matching fault is used when several are injected.
+func (transport *FaultTransport) Inject(operation mobilenig.Operation, call int, fault Fault) *FaultTransport {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.faults = append(transport.faults, scriptedFault{operation: operation, call: call, fault: fault})
+	return transport
+}
+
+// Calls returns the number of requests made for the operation
+func (transport *FaultTransport) Calls(operation mobilenig.Operation) int {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	return transport.calls[operation]
+}
+
+// RoundTrip applies the fault scripted for the request, or sends it with the underlying transport
+func (transport *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := transport.nextFault(operationOf(req.URL.Path))
+	if fault == nil {
+		return transport.transport.RoundTrip(req)
+	}
+
+	var resp *http.Response
+	if fault.Forward {
+		var err error
+		if resp, err = transport.transport.RoundTrip(req); err != nil {
+			return nil, err
+		}
+	}
+
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-req.Context().Done():
+			closeBody(resp)
+			return nil, req.Context().Err()
+		}
+	}
+
+	if fault.Err != nil {
+		closeBody(resp)
+		return nil, fault.Err
+	}
+
+	if fault.StatusCode != 0 {
+		closeBody(resp)
+		resp = &http.Response{
+			Status:        http.StatusText(fault.StatusCode),
+			StatusCode:    fault.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        fault.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewBufferString(fault.Body)),
+			ContentLength: int64(len(fault.Body)),
+			Request:       req,
+		}
+		if resp.Header == nil {
+			resp.Header = make(http.Header)
+		}
+	}
+
+	if resp == nil {
+		return transport.transport.RoundTrip(req)
+	}
+	return resp, nil
+}
+
+// nextFault counts the request and returns the fault scripted for it
+func (transport *FaultTransport) nextFault(operation mobilenig.Operation) *Fault {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.calls[operation]++
+	for _, scripted := range transport.faults {
+		if scripted.operation == operation && (scripted.call == 0 || scripted.call == transport.calls[operation]) {
+			fault := scripted.fault
+			return &fault
+		}
+	}
+	return nil
+}
+
+// operationOf returns the operation of a request path. The base URL may contain a prefix e.g. "/API".
+func operationOf(path string) mobilenig.Operation {
+	for uri, operation := range operations {
+		if strings.HasSuffix(path, uri) {
+			return operation
+		}
+	}
+	return ""
+}
+
+func closeBody(resp *http.Response) {
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+}
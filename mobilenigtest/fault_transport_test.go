@@ -0,0 +1,91 @@
+package mobilenigtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultTransport(t *testing.T) {
+	t.Run("a timeout after sending the 2nd payment", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		transport := NewFaultTransport(nil).Inject(mobilenig.OperationPayDStv, 2, TimeoutAfterSending())
+		client := server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: transport}))
+
+		// Act
+		_, _, firstErr := client.Bills.PayDStv(context.Background(), newTestPayment("1", "1000"))
+		_, _, secondErr := client.Bills.PayDStv(context.Background(), newTestPayment("2", "1000"))
+
+		// Assert
+		assert.NoError(t, firstErr)
+		assert.True(t, errors.Is(secondErr, ErrTimeout))
+		assert.Equal(t, 2, len(server.Payments()))
+		assert.Equal(t, 2, transport.Calls(mobilenig.OperationPayDStv))
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("an HTML error page", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		transport := NewFaultTransport(nil).Inject(mobilenig.OperationQueryDStv, 0, HTMLResponse(http.StatusBadGateway))
+		client := server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: transport}))
+
+		// Act
+		_, resp, err := client.Bills.QueryDStv(context.Background(), "1")
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadGateway, resp.HTTPResponse.StatusCode)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("a slow response", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		transport := NewFaultTransport(nil).Inject(mobilenig.OperationGetBalance, 1, SlowResponse(5*time.Second))
+		client := server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: transport}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		_, _, err := client.Wallet.GetBalance(ctx)
+		balance, _, nextErr := client.Wallet.GetBalance(context.Background())
+
+		// Assert
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.NoError(t, nextErr)
+		assert.Equal(t, "5000", balance.Balance)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("a duplicate transaction ID error", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		transport := NewFaultTransport(nil).Inject(mobilenig.OperationPayDStv, 1, DuplicateTransactionID())
+		client := server.Client(mobilenig.WithHTTPClient(&http.Client{Transport: transport}))
+
+		// Act
+		_, resp, err := client.Bills.PayDStv(context.Background(), newTestPayment("1", "1000"))
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, ErrorCodeDuplicateTransaction, resp.Error.Code)
+		assert.Empty(t, server.Payments())
+
+		// Teardown
+		server.Close()
+	})
+}
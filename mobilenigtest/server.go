@@ -0,0 +1,435 @@
+// Package mobilenigtest provides an in-process fake MobileNig API for testing code which uses the mobilenig client.
+package mobilenigtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+)
+
+const (
+	// ErrorCodeInvalidCredentials is returned when the username or api_key is invalid
+	ErrorCodeInvalidCredentials = mobilenig.ErrorCodeInvalidCredentials
+
+	// ErrorCodeInsufficientBalance is returned when the wallet balance is less than the price of a payment
+	ErrorCodeInsufficientBalance = "ERR102"
+
+	// ErrorCodeInvalidSmartcard is returned when the smartcard or customer number is not registered
+	ErrorCodeInvalidSmartcard = "ERR103"
+
+	// ErrorCodeDuplicateTransaction is returned when a payment is made with a trans_id which has already been used
+	ErrorCodeDuplicateTransaction = "ERR104"
+
+	// ErrorCodeTransactionNotFound is returned when a transaction is queried with an unknown trans_id
+	ErrorCodeTransactionNotFound = mobilenig.ErrorCodeTransactionNotFound
+
+	// ErrorCodeInvalidRequest is returned when a parameter is missing or invalid
+	ErrorCodeInvalidRequest = "ERR106"
+)
+
+// Smartcard is a DStv smartcard registered on the Server
+type Smartcard struct {
+	Number         string
+	CustomerNumber int64
+	FirstName      string
+	LastName       string
+	AccountStatus  string
+	CustomerType   string
+	InvoicePeriod  int
+	DueDate        time.Time
+	PackageName    string
+}
+
+// Payment is a DStv payment made on the Server
+type Payment struct {
+	TransactionID   string
+	SmartcardNumber string
+	ProductCode     string
+	CustomerName    string
+	CustomerNumber  string
+	Price           float64
+	Status          string
+	BalanceAfter    float64
+	CreatedAt       time.Time
+}
+
+// Failure is an error injected in the response of an operation
+type Failure struct {
+	// StatusCode is the HTTP status code of the response. Defaults to 200 like the MobileNig API.
+	StatusCode int
+
+	// Code and Description are returned as a MobileNig error response when Body is empty
+	Code        string
+	Description string
+
+	// Body is returned as is when it is not empty e.g. an HTML error page
+	Body string
+
+	// Delay is how long the server waits before responding
+	Delay time.Duration
+
+	// AfterProcessing processes the request before returning the failure, e.g. a payment is made but its response is lost
+	AfterProcessing bool
+}
+
+type injectedFailure struct {
+	failure   Failure
+	remaining int
+}
+
+// Server is a stateful fake MobileNig API.
+// Payments deduct the price from the wallet balance and can be queried by trans_id.
+type Server struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	username   string
+	apiKey     string
+	balance    float64
+	smartcards map[string]Smartcard
+	payments   map[string]*Payment
+	failures   map[mobilenig.Operation][]*injectedFailure
+	calls      map[mobilenig.Operation]int
+}
+
+// NewServer starts a Server. It must be closed with Close.
+func NewServer(options ...Option) *Server {
+	server := &Server{
+		username:   "mobilenigtest",
+		apiKey:     "mobilenigtest",
+		smartcards: make(map[string]Smartcard),
+		payments:   make(map[string]*Payment),
+		failures:   make(map[mobilenig.Operation][]*injectedFailure),
+		calls:      make(map[mobilenig.Operation]int),
+	}
+
+	for _, option := range options {
+		option.apply(server)
+	}
+
+	server.server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// URL returns the base URL of the server
+func (server *Server) URL() *url.URL {
+	baseURL, _ := url.Parse(server.server.URL)
+	return baseURL
+}
+
+// Client creates a mobilenig.Client for the TestEnvironment which sends requests to the server with valid credentials.
+// The options are applied after the default options.
+func (server *Server) Client(options ...mobilenig.ClientOption) *mobilenig.Client {
+	return mobilenig.New(append([]mobilenig.ClientOption{
+		mobilenig.WithBaseURL(server.URL()),
+		mobilenig.WithEnvironment(mobilenig.TestEnvironment),
+		mobilenig.WithUsername(server.username),
+		mobilenig.WithAPIKey(server.apiKey),
+	}, options...)...)
+}
+
+// Close shuts down the server
+func (server *Server) Close() {
+	server.server.Close()
+}
+
+// Balance returns the wallet balance
+func (server *Server) Balance() float64 {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.balance
+}
+
+// SetBalance changes the wallet balance
+func (server *Server) SetBalance(balance float64) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.balance = balance
+}
+
+// AddSmartcard registers a smartcard
+func (server *Server) AddSmartcard(smartcard Smartcard) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.smartcards[smartcard.Number] = smartcard
+}
+
+// Payment returns the payment with the transaction ID
+func (server *Server) Payment(transactionID string) (Payment, bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	payment, ok := server.payments[transactionID]
+	if !ok {
+		return Payment{}, false
+	}
+	return *payment, true
+}
+
+// Payments returns all the payments ordered by the time they were made
+func (server *Server) Payments() []Payment {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	payments := make([]Payment, 0, len(server.payments))
+	for _, payment := range server.payments {
+		payments = append(payments, *payment)
+	}
+
+	sort.SliceStable(payments, func(i, j int) bool {
+		return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+	})
+	return payments
+}
+
+// SetPaymentStatus changes the status of a payment e.g. to simulate a payment which fails after being accepted
+func (server *Server) SetPaymentStatus(transactionID string, status string) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	payment, ok := server.payments[transactionID]
+	if ok {
+		payment.Status = status
+	}
+	return ok
+}
+
+// Calls returns the number of requests received for the operation
+func (server *Server) Calls(operation mobilenig.Operation) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.calls[operation]
+}
+
+// InjectFailure makes the next requests for the operation fail.
+// The failure is used for the given number of requests, or for every request when times is 0.
+func (server *Server) InjectFailure(operation mobilenig.Operation, failure Failure, times int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.failures[operation] = append(server.failures[operation], &injectedFailure{failure: failure, remaining: times})
+}
+
+// ClearFailures removes all the injected failures
+func (server *Server) ClearFailures() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.failures = make(map[mobilenig.Operation][]*injectedFailure)
+}
+
+// nextFailure returns the next injected failure for the operation
+func (server *Server) nextFailure(operation mobilenig.Operation) *Failure {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	failures := server.failures[operation]
+	if len(failures) == 0 {
+		return nil
+	}
+
+	failure := failures[0].failure
+	if failures[0].remaining > 0 {
+		failures[0].remaining--
+		if failures[0].remaining == 0 {
+			server.failures[operation] = failures[1:]
+		}
+	}
+	return &failure
+}
+
+// operations maps the paths of the MobileNig API to the operations
+var operations = map[string]mobilenig.Operation{
+	"/bills/user_check":  mobilenig.OperationCheckDStvUser,
+	"/bills/get_package": mobilenig.OperationGetDStvPackage,
+	"/bills/dstv":        mobilenig.OperationPayDStv,
+	"/bills/dstv_test":   mobilenig.OperationPayDStv,
+	"/bills/query":       mobilenig.OperationQueryDStv,
+	"/balance":           mobilenig.OperationGetBalance,
+}
+
+func (server *Server) serveHTTP(res http.ResponseWriter, req *http.Request) {
+	operation, ok := operations[req.URL.Path]
+	if !ok {
+		http.NotFound(res, req)
+		return
+	}
+
+	server.mu.Lock()
+	server.calls[operation]++
+	server.mu.Unlock()
+
+	failure := server.nextFailure(operation)
+	if failure == nil {
+		status, body := server.handle(operation, req.URL.Query())
+		writeJSON(res, status, body)
+		return
+	}
+
+	if failure.AfterProcessing {
+		server.handle(operation, req.URL.Query())
+	}
+
+	if failure.Delay > 0 {
+		select {
+		case <-time.After(failure.Delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	writeFailure(res, failure)
+}
+
+// handle processes a request and returns the status code and body of the response
+func (server *Server) handle(operation mobilenig.Operation, query url.Values) (int, interface{}) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if query.Get("username") != server.username || query.Get("api_key") != server.apiKey {
+		return errorResponse(ErrorCodeInvalidCredentials, "Invalid username or api_key")
+	}
+
+	switch operation {
+	case mobilenig.OperationCheckDStvUser:
+		return server.checkDStvUser(query)
+	case mobilenig.OperationGetDStvPackage:
+		return server.getDStvPackage(query)
+	case mobilenig.OperationPayDStv:
+		return server.payDStv(query)
+	case mobilenig.OperationQueryDStv:
+		return server.queryDStv(query)
+	default:
+		return http.StatusOK, map[string]string{"balance": formatAmount(server.balance)}
+	}
+}
+
+func (server *Server) checkDStvUser(query url.Values) (int, interface{}) {
+	smartcard, ok := server.smartcards[query.Get("number")]
+	if !ok {
+		return errorResponse(ErrorCodeInvalidSmartcard, "Invalid smartcard number")
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"details": map[string]interface{}{
+			"accountStatus":  smartcard.AccountStatus,
+			"firstName":      smartcard.FirstName,
+			"lastName":       smartcard.LastName,
+			"customerType":   smartcard.CustomerType,
+			"invoicePeriod":  smartcard.InvoicePeriod,
+			"dueDate":        smartcard.DueDate,
+			"customerNumber": smartcard.CustomerNumber,
+		},
+	}
+}
+
+func (server *Server) getDStvPackage(query url.Values) (int, interface{}) {
+	for _, smartcard := range server.smartcards {
+		if strconv.FormatInt(smartcard.CustomerNumber, 10) == query.Get("customerNumber") {
+			return http.StatusOK, map[string]string{"packageName": smartcard.PackageName}
+		}
+	}
+	return errorResponse(ErrorCodeInvalidSmartcard, "Invalid customer number")
+}
+
+func (server *Server) payDStv(query url.Values) (int, interface{}) {
+	transactionID := query.Get("trans_id")
+	if transactionID == "" {
+		return errorResponse(ErrorCodeInvalidRequest, "trans_id is required")
+	}
+
+	if _, ok := server.payments[transactionID]; ok {
+		return errorResponse(ErrorCodeDuplicateTransaction, "Duplicate transaction ID")
+	}
+
+	price, err := strconv.ParseFloat(query.Get("price"), 64)
+	if err != nil || price <= 0 {
+		return errorResponse(ErrorCodeInvalidRequest, "Invalid price")
+	}
+
+	if _, ok := server.smartcards[query.Get("smartno")]; !ok {
+		return errorResponse(ErrorCodeInvalidSmartcard, "Invalid smartcard number")
+	}
+
+	if server.balance < price {
+		return errorResponse(ErrorCodeInsufficientBalance, "Insufficient balance")
+	}
+
+	server.balance -= price
+	payment := &Payment{
+		TransactionID:   transactionID,
+		SmartcardNumber: query.Get("smartno"),
+		ProductCode:     query.Get("product_code"),
+		CustomerName:    query.Get("customer_name"),
+		CustomerNumber:  query.Get("customer_number"),
+		Price:           price,
+		Status:          "SUCCESSFUL",
+		BalanceAfter:    server.balance,
+		CreatedAt:       time.Now(),
+	}
+	server.payments[transactionID] = payment
+
+	return http.StatusOK, transactionResponse(payment)
+}
+
+func (server *Server) queryDStv(query url.Values) (int, interface{}) {
+	payment, ok := server.payments[query.Get("trans_id")]
+	if !ok {
+		return errorResponse(ErrorCodeTransactionNotFound, "Transaction not found")
+	}
+	return http.StatusOK, transactionResponse(payment)
+}
+
+func transactionResponse(payment *Payment) map[string]interface{} {
+	return map[string]interface{}{
+		"trans_id": payment.TransactionID,
+		"details": map[string]string{
+			"service": "DSTV",
+			"package": payment.ProductCode,
+			"smartno": payment.SmartcardNumber,
+			"price":   formatAmount(payment.Price),
+			"status":  payment.Status,
+			"balance": formatAmount(payment.BalanceAfter),
+		},
+	}
+}
+
+func errorResponse(code string, description string) (int, interface{}) {
+	return http.StatusOK, mobilenig.ErrorResponse{Code: code, Description: description}
+}
+
+func writeFailure(res http.ResponseWriter, failure *Failure) {
+	status := failure.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if failure.Body != "" {
+		res.WriteHeader(status)
+		_, _ = res.Write([]byte(failure.Body))
+		return
+	}
+
+	writeJSON(res, status, mobilenig.ErrorResponse{Code: failure.Code, Description: failure.Description})
+}
+
+func writeJSON(res http.ResponseWriter, status int, body interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(body)
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
@@ -0,0 +1,36 @@
+package mobilenigtest
+
+// Option is applied to a Server when it is created
+type Option interface {
+	apply(server *Server)
+}
+
+type optionFunc func(server *Server)
+
+func (fn optionFunc) apply(server *Server) {
+	fn(server)
+}
+
+// WithCredentials sets the username and API key accepted by the server
+func WithCredentials(username string, apiKey string) Option {
+	return optionFunc(func(server *Server) {
+		server.username = username
+		server.apiKey = apiKey
+	})
+}
+
+// WithBalance sets the initial wallet balance
+func WithBalance(balance float64) Option {
+	return optionFunc(func(server *Server) {
+		server.balance = balance
+	})
+}
+
+// WithSmartcards registers smartcards
+func WithSmartcards(smartcards ...Smartcard) Option {
+	return optionFunc(func(server *Server) {
+		for _, smartcard := range smartcards {
+			server.smartcards[smartcard.Number] = smartcard
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package mobilenigtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer() *Server {
+	return NewServer(
+		WithBalance(5000),
+		WithSmartcards(Smartcard{
+			Number:         "4131953321",
+			CustomerNumber: 275953782,
+			FirstName:      "ESU",
+			LastName:       "INI OBONG BASSEY",
+			AccountStatus:  "OPEN",
+			PackageName:    "DStv Compact",
+		}),
+	)
+}
+
+func newTestPayment(transactionID string, price string) *mobilenig.PayDstvOptions {
+	return &mobilenig.PayDstvOptions{
+		TransactionID:   transactionID,
+		Price:           price,
+		ProductCode:     mobilenig.DstvProductCodeCompact,
+		CustomerName:    "ESU INI OBONG BASSEY",
+		CustomerNumber:  "275953782",
+		SmartcardNumber: "4131953321",
+	}
+}
+
+func TestServer_Payments(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	client := server.Client()
+
+	// Act
+	paid, _, err := client.Bills.PayDStv(context.Background(), newTestPayment("1", "2000"))
+	assert.NoError(t, err)
+	queried, _, queryErr := client.Bills.QueryDStv(context.Background(), "1")
+	balance, _, balanceErr := client.Wallet.GetBalance(context.Background())
+	_, _, duplicateErr := client.Bills.PayDStv(context.Background(), newTestPayment("1", "2000"))
+	_, _, insufficientErr := client.Bills.PayDStv(context.Background(), newTestPayment("2", "4000"))
+
+	// Assert
+	assert.Equal(t, "SUCCESSFUL", paid.Details.Status)
+	assert.Equal(t, "3000", paid.Details.Balance)
+
+	assert.NoError(t, queryErr)
+	assert.Equal(t, paid.Details, queried.Details)
+
+	assert.NoError(t, balanceErr)
+	assert.Equal(t, "3000", balance.Balance)
+
+	assert.Contains(t, duplicateErr.Error(), ErrorCodeDuplicateTransaction)
+	assert.Contains(t, insufficientErr.Error(), ErrorCodeInsufficientBalance)
+
+	assert.Equal(t, float64(3000), server.Balance())
+	assert.Equal(t, 1, len(server.Payments()))
+	assert.Equal(t, 3, server.Calls(mobilenig.OperationPayDStv))
+
+	// Teardown
+	server.Close()
+}
+
+func TestServer_Customers(t *testing.T) {
+	// Arrange
+	server := newTestServer()
+	client := server.Client()
+
+	// Act
+	user, _, err := client.Bills.CheckDStvUser(context.Background(), "4131953321")
+	dstvPackage, _, packageErr := client.Bills.GetDStvPackage(context.Background(), 275953782)
+	_, _, unknownErr := client.Bills.CheckDStvUser(context.Background(), "0000000000")
+	_, _, credentialsErr := server.Client(mobilenig.WithAPIKey("invalid")).Bills.CheckDStvUser(context.Background(), "4131953321")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(275953782), user.Details.CustomerNumber)
+
+	assert.NoError(t, packageErr)
+	assert.Equal(t, "DStv Compact", *dstvPackage)
+
+	assert.Contains(t, unknownErr.Error(), ErrorCodeInvalidSmartcard)
+	assert.Contains(t, credentialsErr.Error(), ErrorCodeInvalidCredentials)
+
+	// Teardown
+	server.Close()
+}
+
+func TestServer_InjectFailure(t *testing.T) {
+	t.Run("a failure is used for the given number of requests", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		client := server.Client()
+		server.InjectFailure(mobilenig.OperationGetBalance, Failure{StatusCode: http.StatusBadGateway, Body: "<html>Bad Gateway</html>"}, 1)
+
+		// Act
+		_, resp, err := client.Wallet.GetBalance(context.Background())
+		_, _, nextErr := client.Wallet.GetBalance(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadGateway, resp.HTTPResponse.StatusCode)
+		assert.NoError(t, nextErr)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("a payment is made when the failure is after processing", func(t *testing.T) {
+		// Arrange
+		server := newTestServer()
+		client := server.Client(mobilenig.WithHTTPClient(&http.Client{Timeout: 50 * time.Millisecond}))
+		server.InjectFailure(mobilenig.OperationPayDStv, Failure{Delay: time.Second, AfterProcessing: true}, 0)
+
+		// Act
+		_, _, err := client.Bills.PayDStv(context.Background(), newTestPayment("1", "2000"))
+
+		// Assert
+		var netErr interface{ Timeout() bool }
+		assert.True(t, errors.As(err, &netErr) && netErr.Timeout())
+
+		payment, ok := server.Payment("1")
+		assert.True(t, ok)
+		assert.Equal(t, float64(2000), payment.Price)
+
+		// Teardown
+		server.Close()
+	})
+}
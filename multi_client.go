@@ -0,0 +1,335 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RoutingStrategy decides which MobileNig account is used first by a MultiClient
+type RoutingStrategy string
+
+const (
+	// RoutingRoundRobin uses the accounts in turn
+	RoutingRoundRobin = RoutingStrategy("ROUND_ROBIN")
+
+	// RoutingByProduct uses the accounts whose ProductCodes contain the product code of the payment first.
+	// Calls which are not payments are routed using RoutingRoundRobin.
+	RoutingByProduct = RoutingStrategy("BY_PRODUCT")
+
+	// RoutingHighestBalance uses the account with the highest wallet balance first.
+	// Balances are cached for a minute and updated with the balance returned by every payment.
+	RoutingHighestBalance = RoutingStrategy("HIGHEST_BALANCE")
+)
+
+const (
+	// multiClientBalanceTTL is how long a wallet balance is reused by RoutingHighestBalance
+	multiClientBalanceTTL = time.Minute
+
+	// multiClientTransactionTTL is how long the account which made a payment is remembered for QueryDStv
+	multiClientTransactionTTL = 24 * time.Hour
+
+	// multiClientMaxTransactions is the maximum number of payments whose account is remembered
+	multiClientMaxTransactions = 10000
+
+	// multiClientRecoveryDelay is how long an unhealthy account is tried last before it is routed like a healthy
+	// account again
+	multiClientRecoveryDelay = 30 * time.Second
+)
+
+// MultiClientAccount is a MobileNig account used by a MultiClient
+type MultiClientAccount struct {
+	// Name identifies the account in the AccountHealth
+	Name string
+
+	// Client is the client configured with the credentials of the account
+	Client *Client
+
+	// ProductCodes are the products which are routed to this account when using RoutingByProduct
+	ProductCodes []DstvProductCode
+}
+
+// AccountHealth is the health of an account in a MultiClient
+type AccountHealth struct {
+	Name                string
+	Healthy             bool
+	ConsecutiveFailures int
+	LastError           string
+	LastErrorAt         time.Time
+	LastSuccessAt       time.Time
+}
+
+type multiClientAccount struct {
+	MultiClientAccount
+
+	mu     sync.Mutex
+	health AccountHealth
+}
+
+// rememberedTransaction is the account which made a payment
+type rememberedTransaction struct {
+	account      *multiClientAccount
+	rememberedAt time.Time
+}
+
+// MultiClient routes API calls to several MobileNig accounts.
+// Calls fail over to the next account only when the request was definitely not processed by MobileNig, i.e. the
+// credentials of the account were rejected or the wallet balance of the account is too low.
+type MultiClient struct {
+	accounts []*multiClientAccount
+	strategy RoutingStrategy
+	next     uint32
+
+	mu           sync.Mutex
+	transactions map[string]rememberedTransaction
+
+	Bills *MultiBillsService
+}
+
+// NewMultiClient creates a MultiClient which routes calls to accounts using strategy
+func NewMultiClient(strategy RoutingStrategy, accounts ...MultiClientAccount) (*MultiClient, error) {
+	if len(accounts) == 0 {
+		return nil, errors.New("mobilenig: a MultiClient needs at least one account")
+	}
+
+	if strategy != RoutingRoundRobin && strategy != RoutingByProduct && strategy != RoutingHighestBalance {
+		return nil, errors.New("mobilenig: unknown routing strategy [" + string(strategy) + "]")
+	}
+
+	multi := &MultiClient{strategy: strategy, transactions: make(map[string]rememberedTransaction)}
+	for _, account := range accounts {
+		if account.Client == nil {
+			return nil, errors.New("mobilenig: the account [" + account.Name + "] has no client")
+		}
+
+		multi.accounts = append(multi.accounts, &multiClientAccount{
+			MultiClientAccount: account,
+			health:             AccountHealth{Name: account.Name, Healthy: true},
+		})
+	}
+
+	multi.Bills = &MultiBillsService{multi: multi}
+	return multi, nil
+}
+
+// Health returns the health of every account
+func (multi *MultiClient) Health() []AccountHealth {
+	health := make([]AccountHealth, 0, len(multi.accounts))
+	for _, account := range multi.accounts {
+		account.mu.Lock()
+		health = append(health, account.health)
+		account.mu.Unlock()
+	}
+	return health
+}
+
+// route returns the accounts in the order in which they should be tried.
+// Unhealthy accounts are tried last until multiClientRecoveryDelay has passed since their last error, then they are
+// tried again in their usual order and stay healthy if the call succeeds.
+func (multi *MultiClient) route(ctx context.Context, productCode DstvProductCode) []*multiClientAccount {
+	start := int(atomic.AddUint32(&multi.next, 1)-1) % len(multi.accounts)
+	accounts := append(append([]*multiClientAccount{}, multi.accounts[start:]...), multi.accounts[:start]...)
+
+	switch {
+	case multi.strategy == RoutingByProduct && productCode != "":
+		sort.SliceStable(accounts, func(i, j int) bool {
+			return accounts[i].handles(productCode) && !accounts[j].handles(productCode)
+		})
+	case multi.strategy == RoutingHighestBalance:
+		balances := make(map[*multiClientAccount]float64, len(accounts))
+		for _, account := range accounts {
+			balances[account] = -1
+			if amount, err := account.Client.cachedBalance(ctx, multiClientBalanceTTL); err == nil {
+				balances[account] = amount
+			}
+		}
+		sort.SliceStable(accounts, func(i, j int) bool {
+			return balances[accounts[i]] > balances[accounts[j]]
+		})
+	}
+
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return accounts[i].isHealthy() && !accounts[j].isHealthy()
+	})
+
+	return accounts
+}
+
+// call executes fn on the routed accounts until it succeeds or fails with an error which is not retryable
+func (multi *MultiClient) call(accounts []*multiClientAccount, fn func(client *Client) (*Response, error)) (*multiClientAccount, error) {
+	var err error
+	for _, account := range accounts {
+		var resp *Response
+		resp, err = fn(account.Client)
+		account.record(resp, err)
+		if err == nil || !isFailoverError(resp, err) {
+			return account, err
+		}
+	}
+	return nil, err
+}
+
+// rememberTransaction records the account which made a payment.
+// Expired transactions are removed, and the oldest transaction is forgotten when there are too many.
+func (multi *MultiClient) rememberTransaction(transactionID string, account *multiClientAccount) {
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+
+	now := time.Now()
+	if len(multi.transactions) >= multiClientMaxTransactions {
+		oldestID, oldest := "", now
+		for id, transaction := range multi.transactions {
+			if now.Sub(transaction.rememberedAt) >= multiClientTransactionTTL {
+				delete(multi.transactions, id)
+				continue
+			}
+			if transaction.rememberedAt.Before(oldest) {
+				oldestID, oldest = id, transaction.rememberedAt
+			}
+		}
+		if len(multi.transactions) >= multiClientMaxTransactions {
+			delete(multi.transactions, oldestID)
+		}
+	}
+
+	multi.transactions[transactionID] = rememberedTransaction{account: account, rememberedAt: now}
+}
+
+func (multi *MultiClient) transactionAccount(transactionID string) *multiClientAccount {
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+
+	transaction, ok := multi.transactions[transactionID]
+	if !ok || time.Since(transaction.rememberedAt) >= multiClientTransactionTTL {
+		return nil
+	}
+	return transaction.account
+}
+
+func (account *multiClientAccount) handles(productCode DstvProductCode) bool {
+	for _, code := range account.ProductCodes {
+		if code == productCode {
+			return true
+		}
+	}
+	return false
+}
+
+// isHealthy returns true when the account is healthy or its last error is older than multiClientRecoveryDelay
+func (account *multiClientAccount) isHealthy() bool {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	return account.health.Healthy || time.Since(account.health.LastErrorAt) >= multiClientRecoveryDelay
+}
+
+// record updates the health of the account with the result of a call.
+// Errors returned by the API for the request itself e.g. an invalid smartcard number don't make an account unhealthy.
+func (account *multiClientAccount) record(resp *Response, err error) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	if err == nil || (resp != nil && resp.Error != nil && !isFailoverError(resp, err)) {
+		account.health.Healthy = true
+		account.health.ConsecutiveFailures = 0
+		account.health.LastSuccessAt = time.Now()
+		return
+	}
+
+	if isContextError(err) {
+		return
+	}
+
+	account.health.Healthy = false
+	account.health.ConsecutiveFailures++
+	account.health.LastError = err.Error()
+	account.health.LastErrorAt = time.Now()
+}
+
+// isFailoverError returns true when the request was refused by the BalanceGuard before it was sent, or MobileNig
+// rejected the credentials of the account or the payment for an insufficient balance. These errors guarantee that a
+// payment was not made, so it is safe to try another account. Any other error may mean that the payment was made.
+func isFailoverError(resp *Response, err error) bool {
+	if errors.Is(err, ErrInsufficientBalance) {
+		return true
+	}
+
+	if resp == nil {
+		return false
+	}
+
+	if resp.HTTPResponse != nil && (resp.HTTPResponse.StatusCode == http.StatusUnauthorized || resp.HTTPResponse.StatusCode == http.StatusForbidden) {
+		return true
+	}
+
+	return resp.Error != nil && (resp.Error.Code == ErrorCodeInvalidCredentials || resp.Error.Code == ErrorCodeInsufficientBalance)
+}
+
+// MultiBillsService routes the `/bills/` endpoints of a MultiClient. It has the same methods as BillsService.
+type MultiBillsService struct {
+	multi *MultiClient
+}
+
+// CheckDStvUser validates a DStv smartcard number
+func (service *MultiBillsService) CheckDStvUser(ctx context.Context, smartcardNumber string) (user *DStvUser, resp *Response, err error) {
+	_, err = service.multi.call(service.multi.route(ctx, ""), func(client *Client) (*Response, error) {
+		user, resp, err = client.Bills.CheckDStvUser(ctx, smartcardNumber)
+		return resp, err
+	})
+	return user, resp, err
+}
+
+// GetDStvPackage returns the client's current DStv package.
+func (service *MultiBillsService) GetDStvPackage(ctx context.Context, customerNumber int64) (dstvPackage *string, resp *Response, err error) {
+	_, err = service.multi.call(service.multi.route(ctx, ""), func(client *Client) (*Response, error) {
+		dstvPackage, resp, err = client.Bills.GetDStvPackage(ctx, customerNumber)
+		return resp, err
+	})
+	return dstvPackage, resp, err
+}
+
+// PayDStv pays a DStv subscription using the first account which accepts the payment.
+func (service *MultiBillsService) PayDStv(ctx context.Context, options *PayDstvOptions) (transaction *DStvTransaction, resp *Response, err error) {
+	if options == nil {
+		return nil, nil, errors.New("options cannot be nil")
+	}
+
+	account, err := service.multi.call(service.multi.route(ctx, options.ProductCode), func(client *Client) (*Response, error) {
+		transaction, resp, err = client.Bills.PayDStv(ctx, options)
+		return resp, err
+	})
+
+	if account != nil && options.TransactionID != "" {
+		service.multi.rememberTransaction(options.TransactionID, account)
+	}
+
+	return transaction, resp, err
+}
+
+// QueryDStv fetches a DStv transaction using the account which made the payment.
+// When the account is not known, the accounts are queried in turn until one of them returns the transaction.
+func (service *MultiBillsService) QueryDStv(ctx context.Context, transactionID string) (transaction *DStvTransaction, resp *Response, err error) {
+	accounts := service.multi.route(ctx, "")
+	if account := service.multi.transactionAccount(transactionID); account != nil {
+		accounts = []*multiClientAccount{account}
+	}
+
+	for _, account := range accounts {
+		transaction, resp, err = account.Client.Bills.QueryDStv(ctx, transactionID)
+		account.record(resp, err)
+		if err == nil {
+			service.multi.rememberTransaction(transactionID, account)
+			return transaction, resp, nil
+		}
+
+		if resp == nil || resp.Error == nil {
+			return transaction, resp, err
+		}
+	}
+
+	return transaction, resp, err
+}
@@ -0,0 +1,277 @@
+package mobilenig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+// multiClientTestAccount is a fake MobileNig account which records the request paths it receives
+type multiClientTestAccount struct {
+	mu      sync.Mutex
+	paths   []string
+	server  *httptest.Server
+	balance string
+	payBody string
+}
+
+func newMultiClientTestAccount(balance string, payBody string) *multiClientTestAccount {
+	account := &multiClientTestAccount{balance: balance, payBody: payBody}
+	account.server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		account.mu.Lock()
+		account.paths = append(account.paths, req.URL.Path)
+		account.mu.Unlock()
+
+		switch req.URL.Path {
+		case "/balance":
+			_, _ = res.Write([]byte(`{"balance":"` + account.balance + `"}`))
+		case "/bills/dstv":
+			_, _ = res.Write([]byte(account.payBody))
+		case "/bills/user_check":
+			_, _ = res.Write([]byte(stubs.CheckDstvUserResponse()))
+		default:
+			_, _ = res.Write([]byte(stubs.QueryDstvTransactionResponse()))
+		}
+	}))
+	return account
+}
+
+func (account *multiClientTestAccount) client() *Client {
+	baseURL, _ := url.Parse(account.server.URL)
+	return New(WithBaseURL(baseURL))
+}
+
+func (account *multiClientTestAccount) requests(path string) int {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	count := 0
+	for _, p := range account.paths {
+		if p == path {
+			count++
+		}
+	}
+	return count
+}
+
+func TestNewMultiClient_Validation(t *testing.T) {
+	_, err := NewMultiClient(RoutingRoundRobin)
+	assert.Error(t, err)
+
+	_, err = NewMultiClient(RoutingStrategy("RANDOM"), MultiClientAccount{Client: New()})
+	assert.Error(t, err)
+
+	_, err = NewMultiClient(RoutingRoundRobin, MultiClientAccount{Name: "no-client"})
+	assert.Error(t, err)
+}
+
+func TestMultiClient_PayDStv_FailsOverOnCredentialErrors(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	first := newMultiClientTestAccount("1000", stubs.ErrorResponse())
+	second := newMultiClientTestAccount("1000", stubs.PayDstvBillResponse())
+	multi, _ := NewMultiClient(
+		RoutingRoundRobin,
+		MultiClientAccount{Name: "first", Client: first.client()},
+		MultiClientAccount{Name: "second", Client: second.client()},
+	)
+
+	// Act
+	transaction, _, err := multi.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223"})
+	_, _, queryErr := multi.Bills.QueryDStv(context.Background(), "122790223")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "122790223", transaction.TransactionID)
+	assert.Equal(t, 1, first.requests("/bills/dstv"))
+	assert.Equal(t, 1, second.requests("/bills/dstv"))
+
+	assert.NoError(t, queryErr)
+	assert.Equal(t, 0, first.requests("/bills/query"))
+	assert.Equal(t, 1, second.requests("/bills/query"))
+
+	health := multi.Health()
+	assert.Equal(t, "first", health[0].Name)
+	assert.False(t, health[0].Healthy)
+	assert.Equal(t, 1, health[0].ConsecutiveFailures)
+	assert.True(t, health[1].Healthy)
+
+	// Teardown
+	first.server.Close()
+	second.server.Close()
+}
+
+func TestMultiClient_PayDStv_FailsOverOnInsufficientBalance(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	first := newMultiClientTestAccount("0", `{"code":"ERR102","description":"Insufficient balance"}`)
+	second := newMultiClientTestAccount("1000", stubs.PayDstvBillResponse())
+	multi, _ := NewMultiClient(
+		RoutingRoundRobin,
+		MultiClientAccount{Name: "first", Client: first.client()},
+		MultiClientAccount{Name: "second", Client: second.client()},
+	)
+
+	// Act
+	_, _, err := multi.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, first.requests("/bills/dstv"))
+	assert.Equal(t, 1, second.requests("/bills/dstv"))
+
+	// Teardown
+	first.server.Close()
+	second.server.Close()
+}
+
+func TestMultiClient_UnhealthyAccountsRecover(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	preferred := newMultiClientTestAccount("1000", stubs.ErrorResponse())
+	backup := newMultiClientTestAccount("1000", stubs.PayDstvBillResponse())
+	multi, _ := NewMultiClient(
+		RoutingByProduct,
+		MultiClientAccount{Name: "preferred", Client: preferred.client(), ProductCodes: []DstvProductCode{DstvProductCodeCompact}},
+		MultiClientAccount{Name: "backup", Client: backup.client()},
+	)
+	options := &PayDstvOptions{ProductCode: DstvProductCodeCompact}
+
+	// Act
+	_, _, _ = multi.Bills.PayDStv(context.Background(), options)
+	_, _, _ = multi.Bills.PayDStv(context.Background(), options)
+	afterFailure := preferred.requests("/bills/dstv")
+
+	account := multi.accounts[0]
+	account.mu.Lock()
+	account.health.LastErrorAt = time.Now().Add(-multiClientRecoveryDelay)
+	account.mu.Unlock()
+
+	_, _, _ = multi.Bills.PayDStv(context.Background(), options)
+
+	// Assert
+	assert.Equal(t, 1, afterFailure)
+	assert.Equal(t, 2, preferred.requests("/bills/dstv"))
+	assert.Equal(t, 3, backup.requests("/bills/dstv"))
+
+	// Teardown
+	preferred.server.Close()
+	backup.server.Close()
+}
+
+func TestMultiClient_PayDStv_DoesNotFailOverOnAmbiguousErrors(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	first := newMultiClientTestAccount("1000", `{"code":"ERR999","description":"Balance update pending, api_key ok"}`)
+	second := newMultiClientTestAccount("1000", stubs.PayDstvBillResponse())
+	multi, _ := NewMultiClient(
+		RoutingRoundRobin,
+		MultiClientAccount{Name: "first", Client: first.client()},
+		MultiClientAccount{Name: "second", Client: second.client()},
+	)
+
+	// Act
+	_, _, err := multi.Bills.PayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223"})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, 1, first.requests("/bills/dstv"))
+	assert.Equal(t, 0, second.requests("/bills/dstv"))
+
+	// Teardown
+	first.server.Close()
+	second.server.Close()
+}
+
+func TestMultiClient_TransactionsExpire(t *testing.T) {
+	// Arrange
+	multi, _ := NewMultiClient(RoutingRoundRobin, MultiClientAccount{Name: "first", Client: New()})
+	multi.rememberTransaction("expired", multi.accounts[0])
+	multi.rememberTransaction("recent", multi.accounts[0])
+
+	expired := multi.transactions["expired"]
+	expired.rememberedAt = time.Now().Add(-multiClientTransactionTTL)
+	multi.transactions["expired"] = expired
+
+	// Act & Assert
+	assert.Nil(t, multi.transactionAccount("expired"))
+	assert.Equal(t, multi.accounts[0], multi.transactionAccount("recent"))
+}
+
+func TestMultiClient_RoutingHighestBalance(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	poor := newMultiClientTestAccount("10", stubs.PayDstvBillResponse())
+	rich := newMultiClientTestAccount("1000", stubs.PayDstvBillResponse())
+	multi, _ := NewMultiClient(
+		RoutingHighestBalance,
+		MultiClientAccount{Name: "poor", Client: poor.client()},
+		MultiClientAccount{Name: "rich", Client: rich.client()},
+	)
+
+	// Act
+	for i := 0; i < 3; i++ {
+		_, _, err := multi.Bills.PayDStv(context.Background(), &PayDstvOptions{})
+		assert.NoError(t, err)
+	}
+
+	// Assert
+	assert.Equal(t, 0, poor.requests("/bills/dstv"))
+	assert.Equal(t, 3, rich.requests("/bills/dstv"))
+
+	// balances are cached and updated with the balance returned by the payments
+	assert.Equal(t, 1, poor.requests("/balance"))
+	assert.Equal(t, 1, rich.requests("/balance"))
+
+	// Teardown
+	poor.server.Close()
+	rich.server.Close()
+}
+
+func TestMultiClient_RoutingByProduct(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	compact := newMultiClientTestAccount("1000", stubs.PayDstvBillResponse())
+	premium := newMultiClientTestAccount("1000", stubs.PayDstvBillResponse())
+	multi, _ := NewMultiClient(
+		RoutingByProduct,
+		MultiClientAccount{Name: "compact", Client: compact.client(), ProductCodes: []DstvProductCode{DstvProductCodeCompact}},
+		MultiClientAccount{Name: "premium", Client: premium.client(), ProductCodes: []DstvProductCode{DstvProductCodePremium}},
+	)
+
+	// Act
+	for i := 0; i < 3; i++ {
+		_, _, _ = multi.Bills.PayDStv(context.Background(), &PayDstvOptions{ProductCode: DstvProductCodePremium})
+	}
+	_, _, _ = multi.Bills.CheckDStvUser(context.Background(), "4131953321")
+	_, _, _ = multi.Bills.CheckDStvUser(context.Background(), "4131953321")
+
+	// Assert
+	assert.Equal(t, 0, compact.requests("/bills/dstv"))
+	assert.Equal(t, 3, premium.requests("/bills/dstv"))
+	assert.Equal(t, 1, compact.requests("/bills/user_check"))
+	assert.Equal(t, 1, premium.requests("/bills/user_check"))
+
+	// Teardown
+	compact.server.Close()
+	premium.server.Close()
+}
@@ -0,0 +1,274 @@
+package mobilenig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Outbox persists payment intents in a LedgerStore and executes them with a worker.
+// Payments which were in flight when the process stopped are resolved with QueryDStv before they are ever sent again.
+type Outbox struct {
+	client       *Client
+	store        LedgerStore
+	pollInterval time.Duration
+	recoverAfter time.Duration
+	isNotFound   func(resp *Response, err error) bool
+}
+
+// OutboxOption are options for constructing an Outbox
+type OutboxOption interface {
+	apply(outbox *Outbox)
+}
+
+type outboxOptionFunc func(outbox *Outbox)
+
+func (fn outboxOptionFunc) apply(outbox *Outbox) {
+	fn(outbox)
+}
+
+// WithOutboxPollInterval sets how often Outbox.Run checks the store for queued payments.
+// By default, the store is checked every second.
+func WithOutboxPollInterval(interval time.Duration) OutboxOption {
+	return outboxOptionFunc(func(outbox *Outbox) {
+		if interval > 0 {
+			outbox.pollInterval = interval
+		}
+	})
+}
+
+// WithOutboxRecoverAfter sets how long after its last update an in-flight payment is resolved by Outbox.Run.
+// A payment which was updated more recently may still be in flight in another worker or process.
+// By default, in-flight payments are resolved 5 minutes after their last update.
+func WithOutboxRecoverAfter(delay time.Duration) OutboxOption {
+	return outboxOptionFunc(func(outbox *Outbox) {
+		if delay >= 0 {
+			outbox.recoverAfter = delay
+		}
+	})
+}
+
+// WithOutboxNotFoundFunc sets the function which decides if the QueryDStv result of an in-flight payment means that
+// MobileNig never received the payment, so it can safely be sent again.
+// By default, only the transaction not found error is treated as not found, see IsTransactionNotFound.
+func WithOutboxNotFoundFunc(isNotFound func(resp *Response, err error) bool) OutboxOption {
+	return outboxOptionFunc(func(outbox *Outbox) {
+		if isNotFound != nil {
+			outbox.isNotFound = isNotFound
+		}
+	})
+}
+
+// NewOutbox creates an Outbox which stores payment intents in store and sends them using client
+func NewOutbox(client *Client, store LedgerStore, options ...OutboxOption) *Outbox {
+	outbox := &Outbox{
+		client:       client,
+		store:        store,
+		pollInterval: time.Second,
+		recoverAfter: 5 * time.Minute,
+		isNotFound:   IsTransactionNotFound,
+	}
+
+	for _, option := range options {
+		option.apply(outbox)
+	}
+
+	return outbox
+}
+
+// EnqueuePayDStv persists a DStv payment intent. A transaction ID is generated when options.TransactionID is empty.
+// It returns ErrDuplicateTransactionID if the transaction ID already exists in the store.
+func (outbox *Outbox) EnqueuePayDStv(ctx context.Context, options *PayDstvOptions, metadata map[string]string) (*LedgerEntry, error) {
+	if options == nil {
+		return nil, errors.New("options cannot be nil")
+	}
+
+	if options.TransactionID == "" {
+		transactionID, err := outbox.client.NewTransactionID()
+		if err != nil {
+			return nil, err
+		}
+		options.TransactionID = transactionID
+	}
+
+	_, err := outbox.store.Get(ctx, options.TransactionID)
+	if err == nil {
+		return nil, ErrDuplicateTransactionID
+	}
+	if !errors.Is(err, ErrLedgerEntryNotFound) {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	entry := &LedgerEntry{
+		TransactionID: options.TransactionID,
+		Operation:     OperationPayDStv.String(),
+		Status:        LedgerStatusQueued,
+		Request:       options.params(),
+		Metadata:      cloneStringMap(metadata),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if err = outbox.store.Save(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return entry.clone(), nil
+}
+
+// unresolvedPaymentError is returned by Outbox.Recover when the outcome of a payment could not be determined
+type unresolvedPaymentError struct {
+	transactionID string
+	err           error
+}
+
+func (err *unresolvedPaymentError) Error() string {
+	return fmt.Sprintf("mobilenig: cannot resolve payment [%s]: %s", err.transactionID, err.err)
+}
+
+func (err *unresolvedPaymentError) Unwrap() error {
+	return err.err
+}
+
+// Run recovers in-flight payments and executes queued payments until the context is cancelled.
+// Only the payments which were last updated before the WithOutboxRecoverAfter delay are recovered, so that payments
+// which are being sent by another worker or process are left alone. Payments which cannot be resolved stay in flight and
+// are retried on the next poll, they are never sent again.
+func (outbox *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(outbox.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := outbox.recover(ctx, time.Now().Add(-outbox.recoverAfter))
+		if unresolved := new(unresolvedPaymentError); err != nil && !errors.As(err, &unresolved) && ctx.Err() == nil {
+			return err
+		}
+
+		if _, err = outbox.Flush(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recover resolves payments which were sent but whose outcome is not known e.g. after a crash.
+// The payment is looked up using QueryDStv, it is queued to be sent again only when MobileNig does not know about it.
+// Payments which cannot be resolved are skipped and the first resolution error is returned.
+// Recover must only be called when no other worker or process is sending payments from the store, use Run otherwise.
+func (outbox *Outbox) Recover(ctx context.Context) error {
+	return outbox.recover(ctx, time.Time{})
+}
+
+// recover resolves the in-flight payments which were last updated before updatedBefore, or all of them when it is zero
+func (outbox *Outbox) recover(ctx context.Context, updatedBefore time.Time) error {
+	entries, err := outbox.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var unresolved error
+	for _, entry := range entries {
+		if entry.Operation != OperationPayDStv.String() || entry.Status.IsTerminal() || entry.Status == LedgerStatusQueued {
+			continue
+		}
+
+		if !updatedBefore.IsZero() && entry.UpdatedAt.After(updatedBefore) {
+			continue
+		}
+
+		err = outbox.resolve(ctx, entry)
+		if resolveErr := new(unresolvedPaymentError); errors.As(err, &resolveErr) {
+			if unresolved == nil {
+				unresolved = err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return unresolved
+}
+
+func (outbox *Outbox) resolve(ctx context.Context, entry *LedgerEntry) error {
+	transaction, resp, err := outbox.client.Bills.QueryDStv(ctx, entry.TransactionID)
+	switch {
+	case err == nil:
+		entry.Status = TransactionLedgerStatus(transaction.Details.Status)
+		entry.Response = append(json.RawMessage(nil), *resp.Body...)
+		entry.Error = ""
+	case outbox.isNotFound(resp, err):
+		entry.Status = LedgerStatusQueued
+		outbox.client.releaseTransactionID(ctx, entry.TransactionID)
+	default:
+		return &unresolvedPaymentError{transactionID: entry.TransactionID, err: err}
+	}
+
+	entry.UpdatedAt = time.Now().UTC()
+	return outbox.store.Save(ctx, entry)
+}
+
+// Flush sends all queued payments in the order in which they were enqueued.
+// It returns the number of payments which were sent.
+func (outbox *Outbox) Flush(ctx context.Context) (int, error) {
+	entries, err := outbox.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, entry := range entries {
+		if entry.Operation != OperationPayDStv.String() || entry.Status != LedgerStatusQueued {
+			continue
+		}
+
+		if err = ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		if err = outbox.send(ctx, entry); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// send executes a queued payment. The entry is marked as pending before the request is sent so that an interrupted
+// payment is resolved by Recover instead of being sent again. When the store is also the ledger of the client, the
+// client marks the entry as pending itself.
+func (outbox *Outbox) send(ctx context.Context, entry *LedgerEntry) error {
+	if outbox.client.ledger != outbox.store {
+		entry.Status = LedgerStatusPending
+		entry.UpdatedAt = time.Now().UTC()
+		if err := outbox.store.Save(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	_, resp, err := outbox.client.Bills.PayDStv(ctx, payDstvOptionsFromParams(entry.Request))
+	switch {
+	case errors.Is(err, ErrTransactionAlreadyRecorded):
+		return err
+	case errors.Is(err, ErrPaymentNotSent) && ctx.Err() != nil:
+		entry.Status, entry.UpdatedAt = LedgerStatusQueued, time.Now().UTC()
+		_ = outbox.store.Save(context.Background(), entry)
+		return ctx.Err()
+	case errors.Is(err, ErrPaymentNotSent):
+		// The payment was refused before it was sent so it is not in flight, and it would be refused again
+		entry.Status, entry.Error, entry.UpdatedAt = LedgerStatusFailed, err.Error(), time.Now().UTC()
+		return outbox.store.Save(context.Background(), entry)
+	}
+
+	return recordPaymentOutcome(ctx, outbox.store, entry, resp, err)
+}
@@ -0,0 +1,313 @@
+package mobilenig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeOutboxTestServer creates a server which responds to /bills/query with queryBody and records the request paths
+func makeOutboxTestServer(queryBody string, paths *[]string) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		*paths = append(*paths, req.URL.Path)
+		mu.Unlock()
+
+		if req.URL.Path == "/bills/query" {
+			_, _ = res.Write([]byte(queryBody))
+			return
+		}
+		_, _ = res.Write([]byte(stubs.PayDstvBillResponse()))
+	}))
+}
+
+func TestOutbox_EnqueueAndFlush(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var paths []string
+	server := makeOutboxTestServer(stubs.QueryDstvTransactionResponse(), &paths)
+	baseURL, _ := url.Parse(server.URL)
+	store := NewMemoryLedgerStore()
+	outbox := NewOutbox(New(WithBaseURL(baseURL)), store)
+
+	// Act
+	entry, err := outbox.EnqueuePayDStv(context.Background(), &PayDstvOptions{Price: "790", SmartcardNumber: "4131953321"}, map[string]string{"row": "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, LedgerStatusQueued, entry.Status)
+
+	sent, err := outbox.Flush(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"/bills/dstv"}, paths)
+
+	saved, _ := store.Get(context.Background(), entry.TransactionID)
+	assert.Equal(t, LedgerStatusSucceeded, saved.Status)
+	assert.Equal(t, "1", saved.Metadata["row"])
+	assert.NotEmpty(t, saved.Response)
+
+	// Teardown
+	server.Close()
+}
+
+func TestOutbox_EnqueueDuplicateTransactionID(t *testing.T) {
+	// Arrange
+	outbox := NewOutbox(New(), NewMemoryLedgerStore())
+	_, err := outbox.EnqueuePayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223"}, nil)
+	assert.NoError(t, err)
+
+	// Act
+	_, err = outbox.EnqueuePayDStv(context.Background(), &PayDstvOptions{TransactionID: "122790223"}, nil)
+
+	// Assert
+	assert.Equal(t, ErrDuplicateTransactionID, err)
+}
+
+func TestOutbox_Recover(t *testing.T) {
+	t.Parallel()
+
+	t.Run("an in-flight payment known by MobileNig is not sent again", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		var paths []string
+		server := makeOutboxTestServer(stubs.QueryDstvTransactionResponse(), &paths)
+		baseURL, _ := url.Parse(server.URL)
+		store := NewMemoryLedgerStore()
+		_ = store.Save(context.Background(), newTestLedgerEntry("122790223", LedgerStatusPending, time.Now()))
+		outbox := NewOutbox(New(WithBaseURL(baseURL)), store)
+
+		// Act
+		err := outbox.Recover(context.Background())
+		_, _ = outbox.Flush(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/bills/query"}, paths)
+
+		entry, _ := store.Get(context.Background(), "122790223")
+		assert.Equal(t, LedgerStatusSucceeded, entry.Status)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("an in-flight payment unknown to MobileNig is sent again", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		var paths []string
+		server := makeOutboxTestServer(stubs.TransactionNotFoundResponse(), &paths)
+		baseURL, _ := url.Parse(server.URL)
+		store := NewMemoryLedgerStore()
+		_ = store.Save(context.Background(), newTestLedgerEntry("122790223", LedgerStatusUnknown, time.Now()))
+		outbox := NewOutbox(New(WithBaseURL(baseURL)), store)
+
+		// Act
+		err := outbox.Recover(context.Background())
+		_, _ = outbox.Flush(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/bills/query", "/bills/dstv"}, paths)
+
+		entry, _ := store.Get(context.Background(), "122790223")
+		assert.Equal(t, LedgerStatusSucceeded, entry.Status)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("an in-flight payment is not sent again on an authentication error", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		var paths []string
+		server := makeOutboxTestServer(stubs.ErrorResponse(), &paths)
+		baseURL, _ := url.Parse(server.URL)
+		store := NewMemoryLedgerStore()
+		_ = store.Save(context.Background(), newTestLedgerEntry("122790223", LedgerStatusUnknown, time.Now()))
+		outbox := NewOutbox(New(WithBaseURL(baseURL)), store)
+
+		// Act
+		err := outbox.Recover(context.Background())
+		_, _ = outbox.Flush(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, []string{"/bills/query"}, paths)
+
+		entry, _ := store.Get(context.Background(), "122790223")
+		assert.Equal(t, LedgerStatusUnknown, entry.Status)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("an in-flight payment is not sent again when it cannot be resolved", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		var paths []string
+		server := makeOutboxTestServer("<not-a-json></not-a-json>", &paths)
+		baseURL, _ := url.Parse(server.URL)
+		store := NewMemoryLedgerStore()
+		_ = store.Save(context.Background(), newTestLedgerEntry("122790223", LedgerStatusPending, time.Now()))
+		outbox := NewOutbox(New(WithBaseURL(baseURL)), store)
+
+		// Act
+		err := outbox.Recover(context.Background())
+		_, _ = outbox.Flush(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, []string{"/bills/query"}, paths)
+
+		entry, _ := store.Get(context.Background(), "122790223")
+		assert.Equal(t, LedgerStatusPending, entry.Status)
+
+		// Teardown
+		server.Close()
+	})
+}
+
+func TestOutbox_Recover_ResendsWithTransactionIDGuard(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var payments int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/bills/query" {
+			_, _ = res.Write([]byte(stubs.TransactionNotFoundResponse()))
+			return
+		}
+		if atomic.AddInt32(&payments, 1) == 1 {
+			_, _ = res.Write([]byte("<not-a-json></not-a-json>"))
+			return
+		}
+		_, _ = res.Write([]byte(stubs.PayDstvBillResponse()))
+	}))
+	baseURL, _ := url.Parse(server.URL)
+	store := NewMemoryLedgerStore()
+	outbox := NewOutbox(New(WithBaseURL(baseURL), WithTransactionIDGuard(nil)), store)
+
+	entry, err := outbox.EnqueuePayDStv(context.Background(), &PayDstvOptions{Price: "790", SmartcardNumber: "4131953321"}, nil)
+	assert.NoError(t, err)
+	_, _ = outbox.Flush(context.Background())
+
+	// Act
+	err = outbox.Recover(context.Background())
+	_, flushErr := outbox.Flush(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, flushErr)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&payments))
+
+	saved, _ := store.Get(context.Background(), entry.TransactionID)
+	assert.Equal(t, LedgerStatusSucceeded, saved.Status)
+
+	// Teardown
+	server.Close()
+}
+
+func TestOutbox_Flush_RefusedPaymentsFail(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var paths []string
+	server := makeOutboxTestServer(stubs.TransactionNotFoundResponse(), &paths)
+	baseURL, _ := url.Parse(server.URL)
+	store := NewMemoryLedgerStore()
+	outbox := NewOutbox(New(WithBaseURL(baseURL), WithPaymentPolicy(&PaymentPolicy{MaxAmount: 500})), store)
+	entry, _ := outbox.EnqueuePayDStv(context.Background(), &PayDstvOptions{Price: "790", SmartcardNumber: "4131953321"}, nil)
+
+	// Act
+	_, err := outbox.Flush(context.Background())
+	recoverErr := outbox.Recover(context.Background())
+	sent, _ := outbox.Flush(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, recoverErr)
+	assert.Equal(t, 0, sent)
+	assert.Empty(t, paths)
+
+	saved, _ := store.Get(context.Background(), entry.TransactionID)
+	assert.Equal(t, LedgerStatusFailed, saved.Status)
+	assert.Contains(t, saved.Error, ErrPolicyViolation.Error())
+
+	// Teardown
+	server.Close()
+}
+
+func TestOutbox_Run_LeavesRecentInFlightPayments(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var paths []string
+	server := makeOutboxTestServer(stubs.TransactionNotFoundResponse(), &paths)
+	baseURL, _ := url.Parse(server.URL)
+	store := NewMemoryLedgerStore()
+	_ = store.Save(context.Background(), newTestLedgerEntry("recent", LedgerStatusPending, time.Now()))
+	_ = store.Save(context.Background(), newTestLedgerEntry("stale", LedgerStatusPending, time.Now().Add(-time.Hour)))
+	outbox := NewOutbox(New(WithBaseURL(baseURL)), store, WithOutboxPollInterval(time.Hour), WithOutboxRecoverAfter(time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := outbox.Run(ctx)
+
+	// Assert
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	recent, _ := store.Get(context.Background(), "recent")
+	assert.Equal(t, LedgerStatusPending, recent.Status)
+	stale, _ := store.Get(context.Background(), "stale")
+	assert.Equal(t, LedgerStatusSucceeded, stale.Status)
+
+	// Teardown
+	server.Close()
+}
+
+func TestOutbox_Run(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var paths []string
+	server := makeOutboxTestServer(stubs.QueryDstvTransactionResponse(), &paths)
+	baseURL, _ := url.Parse(server.URL)
+	store := NewMemoryLedgerStore()
+	outbox := NewOutbox(New(WithBaseURL(baseURL)), store, WithOutboxPollInterval(10*time.Millisecond))
+	entry, _ := outbox.EnqueuePayDStv(context.Background(), &PayDstvOptions{Price: "790"}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := outbox.Run(ctx)
+
+	// Assert
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	saved, _ := store.Get(context.Background(), entry.TransactionID)
+	assert.Equal(t, LedgerStatusSucceeded, saved.Status)
+
+	// Teardown
+	server.Close()
+}
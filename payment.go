@@ -0,0 +1,238 @@
+package mobilenig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPaymentNotSent matches the errors of payments which were refused before the request was sent e.g. by the
+// PaymentPolicy, the live payment lock or the BalanceGuard. MobileNig never received such a payment.
+var ErrPaymentNotSent = errors.New("mobilenig: the payment was not sent")
+
+// notSentError is an error returned before a payment request was sent
+type notSentError struct {
+	err error
+}
+
+func (err *notSentError) Error() string {
+	return err.err.Error()
+}
+
+func (err *notSentError) Unwrap() error {
+	return err.err
+}
+
+// Is makes the error match ErrPaymentNotSent
+func (err *notSentError) Is(target error) bool {
+	return target == ErrPaymentNotSent
+}
+
+// payment is a money-moving API request e.g PayDStv
+type payment struct {
+	endpoint        endpoint
+	transactionID   string
+	price           string
+	productCode     string
+	smartcardNumber string
+	customerNumber  string
+	params          map[string]string
+
+	// validate checks the options of the payment in dry-run mode
+	validate func() error
+
+	// policyDay is the day whose PaymentPolicy daily totals include the payment
+	policyDay string
+
+	// balanceReserved is the amount deducted from the cached balance by the BalanceGuard, and balanceGeneration is the
+	// generation of the cached balance it was deducted from
+	balanceReserved   float64
+	balanceGeneration uint64
+}
+
+// pay sends a payment request after it has been allowed by the live payment lock, the PaymentPolicy and the BalanceGuard.
+// Errors returned before the request is sent match ErrPaymentNotSent.
+// When a LedgerStore is configured, the intent is recorded before the request is sent and updated with the outcome.
+// The request is not sent if the intent cannot be recorded.
+// In dry-run mode, the request is built and returned in the Response without any side effects.
+func (client *Client) pay(ctx context.Context, payment *payment) (*Response, error) {
+	if client.isDryRun(ctx) {
+		return client.dryRunPayment(ctx, payment)
+	}
+
+	if client.policyErr != nil {
+		return nil, &notSentError{err: client.policyErr}
+	}
+
+	if err := client.checkLivePaymentLock(payment.endpoint); err != nil {
+		return nil, &notSentError{err: err}
+	}
+
+	if client.policy != nil {
+		if err := client.policy.evaluate(payment); err != nil {
+			return nil, &notSentError{err: err}
+		}
+	}
+
+	resp, sent, err := client.send(ctx, payment)
+
+	if !sent || paymentStatus(resp, err) == LedgerStatusFailed {
+		if client.policy != nil {
+			client.policy.release(payment)
+		}
+		client.refundBalance(payment)
+	}
+
+	if !sent && err != nil {
+		return resp, &notSentError{err: err}
+	}
+	return resp, err
+}
+
+// send checks the wallet balance, reserves the transaction ID, records the payment in the ledger and sends the payment
+// request. The transaction ID is released when the request is not sent. The bool return value reports whether the
+// request was sent.
+func (client *Client) send(ctx context.Context, payment *payment) (*Response, bool, error) {
+	if err := client.checkBalance(ctx, payment); err != nil {
+		return nil, false, err
+	}
+
+	if err := client.reserveTransactionID(ctx, payment.transactionID); err != nil {
+		return nil, false, err
+	}
+
+	entry, err := client.recordPaymentIntent(ctx, payment)
+	if err != nil {
+		client.releaseTransactionID(ctx, payment.transactionID)
+		return nil, false, err
+	}
+
+	request, err := client.newRequest(ctx, payment.endpoint, payment.params)
+	if err == nil {
+		err = client.prepare(request)
+	}
+	if err != nil {
+		client.releaseTransactionID(ctx, payment.transactionID)
+		if entry != nil {
+			entry.Status, entry.Error, entry.UpdatedAt = LedgerStatusFailed, err.Error(), time.Now().UTC()
+			_ = client.ledger.Save(context.Background(), entry)
+		}
+		return nil, false, err
+	}
+
+	resp, err := client.exchange(request)
+	client.updateBalance(resp)
+
+	if entry != nil {
+		// The payment was sent, so a failure to record its response in the audit log doesn't change its outcome.
+		outcomeErr := err
+		if errors.Is(err, ErrAuditFailed) {
+			outcomeErr = nil
+		}
+		if ledgerErr := recordPaymentOutcome(ctx, client.ledger, entry, resp, outcomeErr); ledgerErr != nil && err == nil {
+			err = ledgerErr
+		}
+	}
+
+	return resp, true, err
+}
+
+// recordPaymentIntent saves the payment as pending before it is sent.
+// A transaction ID which is already in the ledger can only be sent again when it is queued in an Outbox or when the
+// previous attempt failed, otherwise ErrTransactionAlreadyRecorded is returned and the existing entry is kept.
+func (client *Client) recordPaymentIntent(ctx context.Context, payment *payment) (*LedgerEntry, error) {
+	if client.ledger == nil {
+		return nil, nil
+	}
+
+	existing, err := client.ledger.Get(ctx, payment.transactionID)
+	if err != nil && !errors.Is(err, ErrLedgerEntryNotFound) {
+		return nil, fmt.Errorf("mobilenig: cannot record payment intent: %w", err)
+	}
+	if existing != nil && existing.Status != LedgerStatusQueued && existing.Status != LedgerStatusFailed {
+		return nil, fmt.Errorf("%w: [%s] is %s", ErrTransactionAlreadyRecorded, payment.transactionID, existing.Status)
+	}
+
+	now := time.Now().UTC()
+	entry := &LedgerEntry{
+		TransactionID: payment.transactionID,
+		Operation:     payment.endpoint.operation.String(),
+		Status:        LedgerStatusPending,
+		Request:       cloneStringMap(payment.params),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if existing != nil {
+		entry.CreatedAt = existing.CreatedAt
+		entry.Metadata = existing.Metadata
+	}
+
+	if err := client.ledger.Save(ctx, entry); err != nil {
+		return nil, fmt.Errorf("mobilenig: cannot record payment intent: %w", err)
+	}
+
+	return entry, nil
+}
+
+// recordPaymentOutcome updates the ledger entry with the response of the payment request
+func recordPaymentOutcome(ctx context.Context, store LedgerStore, entry *LedgerEntry, resp *Response, err error) error {
+	entry.Status = paymentStatus(resp, err)
+	entry.UpdatedAt = time.Now().UTC()
+
+	if resp != nil && resp.Body != nil && json.Valid(*resp.Body) {
+		entry.Response = append(json.RawMessage(nil), *resp.Body...)
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	// The outcome must be recorded even when the payment context has been cancelled after the request.
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+
+	if err = store.Save(ctx, entry); err != nil {
+		return fmt.Errorf("mobilenig: cannot record outcome of payment [%s]: %w", entry.TransactionID, err)
+	}
+
+	return nil
+}
+
+// paymentStatus determines the LedgerStatus of a payment from the API response
+func paymentStatus(resp *Response, err error) LedgerStatus {
+	if resp == nil || resp.Body == nil {
+		return LedgerStatusUnknown
+	}
+
+	if resp.Error != nil && len(resp.Error.Description) > 0 {
+		return LedgerStatusFailed
+	}
+
+	payload := new(struct {
+		Details struct {
+			Status string `json:"status"`
+		} `json:"details"`
+	})
+	if json.Unmarshal(*resp.Body, payload) != nil || err != nil {
+		return LedgerStatusUnknown
+	}
+
+	return TransactionLedgerStatus(payload.Details.Status)
+}
+
+// TransactionLedgerStatus maps the status of a MobileNig transaction to a LedgerStatus.
+// Statuses other than SUCCESSFUL and FAILED are LedgerStatusPending.
+func TransactionLedgerStatus(status string) LedgerStatus {
+	switch status {
+	case "SUCCESSFUL":
+		return LedgerStatusSucceeded
+	case "FAILED":
+		return LedgerStatusFailed
+	default:
+		return LedgerStatusPending
+	}
+}
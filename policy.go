@@ -0,0 +1,263 @@
+package mobilenig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPolicyViolation is matched by errors.Is for every *PolicyViolation
+var ErrPolicyViolation = errors.New("mobilenig: payment policy violation")
+
+// PolicyRule is the name of a rule in a PaymentPolicy
+type PolicyRule string
+
+const (
+	// PolicyRuleMaxAmount limits the price of a single payment
+	PolicyRuleMaxAmount = PolicyRule("max_amount")
+
+	// PolicyRuleDailyCapPerSmartcard limits the total amount paid for a smartcard in a day
+	PolicyRuleDailyCapPerSmartcard = PolicyRule("daily_cap_per_smartcard")
+
+	// PolicyRuleDailyCapPerCustomer limits the total amount paid for a customer in a day
+	PolicyRuleDailyCapPerCustomer = PolicyRule("daily_cap_per_customer")
+
+	// PolicyRuleAllowedProductCodes limits the product codes which can be paid
+	PolicyRuleAllowedProductCodes = PolicyRule("allowed_product_codes")
+
+	// PolicyRuleAllowedHours limits the hours of the day in which payments can be made
+	PolicyRuleAllowedHours = PolicyRule("allowed_hours")
+)
+
+// PolicyViolation is returned when a payment is rejected by the PaymentPolicy
+type PolicyViolation struct {
+	Rule    PolicyRule
+	Message string
+}
+
+// Error returns the error message
+func (violation *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s [%s]: %s", ErrPolicyViolation, violation.Rule, violation.Message)
+}
+
+// Is makes errors.Is(err, ErrPolicyViolation) return true
+func (violation *PolicyViolation) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// HourRange is a range of hours in a day. Start is inclusive and End is exclusive e.g {Start: 8, End: 20}.
+// The range wraps around midnight when Start is greater than End.
+type HourRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (hours HourRange) contains(hour int) bool {
+	if hours.Start <= hours.End {
+		return hour >= hours.Start && hour < hours.End
+	}
+	return hour >= hours.Start || hour < hours.End
+}
+
+// PaymentPolicy contains the rules which are evaluated before a payment is sent.
+// A zero value disables a rule. The daily totals are kept in memory by the Client, so they start from zero when the
+// process is restarted.
+type PaymentPolicy struct {
+	MaxAmount            float64           `json:"max_amount"`
+	DailyCapPerSmartcard float64           `json:"daily_cap_per_smartcard"`
+	DailyCapPerCustomer  float64           `json:"daily_cap_per_customer"`
+	AllowedProductCodes  []DstvProductCode `json:"allowed_product_codes"`
+	AllowedHours         *HourRange        `json:"allowed_hours"`
+
+	// Timezone is the IANA time zone used for the AllowedHours and daily caps. Defaults to UTC when empty.
+	Timezone string `json:"timezone"`
+}
+
+// LoadPaymentPolicy reads a PaymentPolicy from a JSON file
+func LoadPaymentPolicy(path string) (*PaymentPolicy, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := new(PaymentPolicy)
+	if err = json.Unmarshal(contents, policy); err != nil {
+		return nil, fmt.Errorf("mobilenig: invalid payment policy [%s]: %w", path, err)
+	}
+
+	if err = policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// Validate checks that the rules in the policy are valid
+func (policy *PaymentPolicy) Validate() error {
+	if policy.MaxAmount < 0 || policy.DailyCapPerSmartcard < 0 || policy.DailyCapPerCustomer < 0 {
+		return errors.New("mobilenig: payment policy amounts cannot be negative")
+	}
+
+	if hours := policy.AllowedHours; hours != nil && (hours.Start < 0 || hours.Start > 23 || hours.End < 0 || hours.End > 24) {
+		return fmt.Errorf("mobilenig: invalid payment policy hours [%d-%d]", hours.Start, hours.End)
+	}
+
+	if _, err := time.LoadLocation(policy.Timezone); err != nil {
+		return fmt.Errorf("mobilenig: invalid payment policy timezone [%s]: %w", policy.Timezone, err)
+	}
+
+	return nil
+}
+
+// policyEngine evaluates a PaymentPolicy and keeps track of the amounts spent each day
+type policyEngine struct {
+	policy   *PaymentPolicy
+	location *time.Location
+	now      func() time.Time
+
+	mu    sync.Mutex
+	day   string
+	spent map[string]float64
+}
+
+func newPolicyEngine(policy *PaymentPolicy) (*policyEngine, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(policy.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policyEngine{
+		policy:   policy,
+		location: location,
+		now:      time.Now,
+		spent:    make(map[string]float64),
+	}, nil
+}
+
+// evaluate checks the payment against the policy.
+// The price is added to the daily totals of the current day when the payment is allowed, use release to remove it.
+func (engine *policyEngine) evaluate(payment *payment) error {
+	price, err := strconv.ParseFloat(payment.price, 64)
+	if err != nil {
+		return fmt.Errorf("mobilenig: invalid price [%s]: %w", payment.price, err)
+	}
+
+	// NaN and infinite prices would pass every comparison in the rules below
+	if math.IsNaN(price) || math.IsInf(price, 0) || price <= 0 {
+		return fmt.Errorf("mobilenig: invalid price [%s]: it is not a positive amount", payment.price)
+	}
+
+	policy := engine.policy
+	now := engine.now().In(engine.location)
+
+	if policy.MaxAmount > 0 && price > policy.MaxAmount {
+		return &PolicyViolation{
+			Rule:    PolicyRuleMaxAmount,
+			Message: fmt.Sprintf("price %.2f is more than the maximum amount %.2f", price, policy.MaxAmount),
+		}
+	}
+
+	if len(policy.AllowedProductCodes) > 0 && !containsProductCode(policy.AllowedProductCodes, payment.productCode) {
+		return &PolicyViolation{
+			Rule:    PolicyRuleAllowedProductCodes,
+			Message: fmt.Sprintf("product code [%s] is not allowed", payment.productCode),
+		}
+	}
+
+	if policy.AllowedHours != nil && !policy.AllowedHours.contains(now.Hour()) {
+		return &PolicyViolation{
+			Rule: PolicyRuleAllowedHours,
+			Message: fmt.Sprintf(
+				"payments are allowed from %02d:00 to %02d:00, it is %s",
+				policy.AllowedHours.Start,
+				policy.AllowedHours.End,
+				now.Format("15:04"),
+			),
+		}
+	}
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	if day := now.Format("2006-01-02"); day != engine.day {
+		engine.day = day
+		engine.spent = make(map[string]float64)
+	}
+
+	caps := []struct {
+		rule  PolicyRule
+		key   string
+		limit float64
+	}{
+		{rule: PolicyRuleDailyCapPerSmartcard, key: dailyCapKey("smartcard", payment.smartcardNumber), limit: policy.DailyCapPerSmartcard},
+		{rule: PolicyRuleDailyCapPerCustomer, key: dailyCapKey("customer", payment.customerNumber), limit: policy.DailyCapPerCustomer},
+	}
+
+	for _, dailyCap := range caps {
+		if dailyCap.key != "" && dailyCap.limit > 0 && engine.spent[dailyCap.key]+price > dailyCap.limit {
+			return &PolicyViolation{
+				Rule:    dailyCap.rule,
+				Message: fmt.Sprintf("%.2f has been paid today, the daily cap is %.2f", engine.spent[dailyCap.key], dailyCap.limit),
+			}
+		}
+	}
+
+	for _, dailyCap := range caps {
+		if dailyCap.key != "" {
+			engine.spent[dailyCap.key] += price
+		}
+	}
+	payment.policyDay = engine.day
+
+	return nil
+}
+
+// dailyCapKey returns the key of the daily total of a smartcard or customer.
+// Payments without a value are not counted, so they don't all share the same daily cap.
+func dailyCapKey(kind string, value string) string {
+	if strings.TrimSpace(value) == "" {
+		return ""
+	}
+	return kind + ":" + value
+}
+
+// release removes the price of a payment which failed from the daily totals.
+// Nothing is removed when the day has changed since the payment was evaluated.
+func (engine *policyEngine) release(payment *payment) {
+	price, err := strconv.ParseFloat(payment.price, 64)
+	if err != nil {
+		return
+	}
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	if payment.policyDay == "" || payment.policyDay != engine.day {
+		return
+	}
+
+	for _, key := range []string{dailyCapKey("smartcard", payment.smartcardNumber), dailyCapKey("customer", payment.customerNumber)} {
+		if spent, ok := engine.spent[key]; ok {
+			engine.spent[key] = spent - price
+		}
+	}
+}
+
+func containsProductCode(codes []DstvProductCode, code string) bool {
+	for _, allowed := range codes {
+		if string(allowed) == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPolicyPayment(price string, productCode DstvProductCode) *payment {
+	return &payment{
+		price:           price,
+		productCode:     string(productCode),
+		smartcardNumber: "4131953321",
+		customerNumber:  "275953782",
+	}
+}
+
+func newTestPolicyEngine(t *testing.T, policy *PaymentPolicy) *policyEngine {
+	engine, err := newPolicyEngine(policy)
+	assert.NoError(t, err)
+	return engine
+}
+
+func assertPolicyViolation(t *testing.T, err error, rule PolicyRule) {
+	violation := new(PolicyViolation)
+	assert.True(t, errors.As(err, &violation))
+	assert.Equal(t, rule, violation.Rule)
+	assert.True(t, errors.Is(err, ErrPolicyViolation))
+}
+
+func TestPolicyEngine_Evaluate(t *testing.T) {
+	t.Run("max amount", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, &PaymentPolicy{MaxAmount: 1000})
+
+		// Act
+		allowed := engine.evaluate(newTestPolicyPayment("1000", DstvProductCodePremium))
+		rejected := engine.evaluate(newTestPolicyPayment("1000.01", DstvProductCodePremium))
+
+		// Assert
+		assert.NoError(t, allowed)
+		assertPolicyViolation(t, rejected, PolicyRuleMaxAmount)
+	})
+
+	t.Run("prices which are not positive amounts are rejected", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, &PaymentPolicy{MaxAmount: 1000, DailyCapPerSmartcard: 1500})
+
+		for _, price := range []string{"NaN", "+Inf", "-Inf", "-1000", "0"} {
+			// Act
+			err := engine.evaluate(newTestPolicyPayment(price, DstvProductCodePremium))
+
+			// Assert
+			assert.Error(t, err, price)
+			assert.False(t, errors.Is(err, ErrPolicyViolation), price)
+		}
+		assert.Empty(t, engine.spent)
+	})
+
+	t.Run("allowed product codes", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, &PaymentPolicy{AllowedProductCodes: []DstvProductCode{DstvProductCodeCompact}})
+
+		// Act
+		allowed := engine.evaluate(newTestPolicyPayment("1000", DstvProductCodeCompact))
+		rejected := engine.evaluate(newTestPolicyPayment("1000", DstvProductCodePremium))
+
+		// Assert
+		assert.NoError(t, allowed)
+		assertPolicyViolation(t, rejected, PolicyRuleAllowedProductCodes)
+	})
+
+	t.Run("allowed hours wrap around midnight", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, &PaymentPolicy{AllowedHours: &HourRange{Start: 22, End: 6}})
+
+		// Act
+		engine.now = func() time.Time { return time.Date(2021, 1, 1, 23, 0, 0, 0, time.UTC) }
+		allowed := engine.evaluate(newTestPolicyPayment("1000", DstvProductCodeCompact))
+
+		engine.now = func() time.Time { return time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC) }
+		rejected := engine.evaluate(newTestPolicyPayment("1000", DstvProductCodeCompact))
+
+		// Assert
+		assert.NoError(t, allowed)
+		assertPolicyViolation(t, rejected, PolicyRuleAllowedHours)
+	})
+
+	t.Run("daily caps are reset every day and released for failed payments", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, &PaymentPolicy{DailyCapPerSmartcard: 1500, DailyCapPerCustomer: 5000})
+		engine.now = func() time.Time { return time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+		failed := newTestPolicyPayment("1000", DstvProductCodeCompact)
+
+		// Act & Assert
+		assert.NoError(t, engine.evaluate(failed))
+		assertPolicyViolation(t, engine.evaluate(newTestPolicyPayment("1000", DstvProductCodeCompact)), PolicyRuleDailyCapPerSmartcard)
+
+		engine.release(failed)
+		assert.NoError(t, engine.evaluate(newTestPolicyPayment("1000", DstvProductCodeCompact)))
+
+		engine.now = func() time.Time { return time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC) }
+		assert.NoError(t, engine.evaluate(newTestPolicyPayment("1000", DstvProductCodeCompact)))
+	})
+
+	t.Run("a payment released after the day changed does not reduce the new day's total", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, &PaymentPolicy{DailyCapPerSmartcard: 1500})
+		engine.now = func() time.Time { return time.Date(2021, 1, 1, 23, 59, 0, 0, time.UTC) }
+		yesterday := newTestPolicyPayment("1000", DstvProductCodeCompact)
+		assert.NoError(t, engine.evaluate(yesterday))
+
+		engine.now = func() time.Time { return time.Date(2021, 1, 2, 0, 1, 0, 0, time.UTC) }
+		assert.NoError(t, engine.evaluate(newTestPolicyPayment("1000", DstvProductCodeCompact)))
+
+		// Act
+		engine.release(yesterday)
+
+		// Assert
+		assertPolicyViolation(t, engine.evaluate(newTestPolicyPayment("1000", DstvProductCodeCompact)), PolicyRuleDailyCapPerSmartcard)
+	})
+
+	t.Run("payments without a customer number don't share a daily cap", func(t *testing.T) {
+		// Arrange
+		engine := newTestPolicyEngine(t, &PaymentPolicy{DailyCapPerCustomer: 1500})
+		first := newTestPolicyPayment("1000", DstvProductCodeCompact)
+		first.customerNumber = ""
+		second := newTestPolicyPayment("1000", DstvProductCodeCompact)
+		second.customerNumber, second.smartcardNumber = "", "7027114481"
+
+		// Act & Assert
+		assert.NoError(t, engine.evaluate(first))
+		assert.NoError(t, engine.evaluate(second))
+	})
+}
+
+func TestNewPolicyEngine_InvalidTimezone(t *testing.T) {
+	// Act
+	_, err := newPolicyEngine(&PaymentPolicy{Timezone: "Mars/Olympus_Mons"})
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestLoadPaymentPolicy(t *testing.T) {
+	t.Run("it loads a policy from a JSON file", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "policy.json")
+		_ = ioutil.WriteFile(path, []byte(`{
+			"max_amount": 20000,
+			"daily_cap_per_smartcard": 40000,
+			"allowed_product_codes": ["COMPE36", "PRWE36"],
+			"allowed_hours": {"start": 8, "end": 20},
+			"timezone": "Africa/Lagos"
+		}`), 0o600)
+
+		// Act
+		policy, err := LoadPaymentPolicy(path)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 20000.0, policy.MaxAmount)
+		assert.Equal(t, 40000.0, policy.DailyCapPerSmartcard)
+		assert.Equal(t, []DstvProductCode{DstvProductCodeCompact, DstvProductCodePremium}, policy.AllowedProductCodes)
+		assert.Equal(t, &HourRange{Start: 8, End: 20}, policy.AllowedHours)
+		assert.Equal(t, "Africa/Lagos", policy.Timezone)
+	})
+
+	t.Run("it returns an error for an invalid policy", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "policy.json")
+		_ = ioutil.WriteFile(path, []byte(`{"allowed_hours": {"start": 25, "end": 20}}`), 0o600)
+
+		// Act
+		_, err := LoadPaymentPolicy(path)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
+
+func TestBillsService_PayDStv_PolicyViolation(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+	}))
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL), WithPaymentPolicy(&PaymentPolicy{MaxAmount: 500}))
+
+	// Act
+	_, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{Price: "790"})
+
+	// Assert
+	assertPolicyViolation(t, err, PolicyRuleMaxAmount)
+	assert.Equal(t, 0, requests)
+
+	// Teardown
+	server.Close()
+}
+
+func TestBillsService_PayDStv_InvalidPolicyRefusesPayments(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+	}))
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL), WithPaymentPolicy(&PaymentPolicy{MaxAmount: 500, Timezone: "Lagos"}))
+
+	// Act
+	_, _, err := client.Bills.PayDStv(context.Background(), &PayDstvOptions{Price: "100"})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timezone")
+	assert.Equal(t, 0, requests)
+
+	// Teardown
+	server.Close()
+}
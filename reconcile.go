@@ -0,0 +1,322 @@
+package mobilenig
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReconciliationStatus is the result of comparing a local transaction with MobileNig's view of it
+type ReconciliationStatus string
+
+const (
+	// ReconciliationMatched means that the amount and status of the transaction are the same locally and on MobileNig
+	ReconciliationMatched = ReconciliationStatus("MATCHED")
+
+	// ReconciliationAmountMismatch means that MobileNig charged a different amount
+	ReconciliationAmountMismatch = ReconciliationStatus("AMOUNT_MISMATCH")
+
+	// ReconciliationStatusMismatch means that the final status on MobileNig is not the expected status
+	ReconciliationStatusMismatch = ReconciliationStatus("STATUS_MISMATCH")
+
+	// ReconciliationMissingRemotely means that MobileNig has no transaction with the transaction ID
+	ReconciliationMissingRemotely = ReconciliationStatus("MISSING_REMOTELY")
+
+	// ReconciliationPending means that the transaction is still pending on MobileNig
+	ReconciliationPending = ReconciliationStatus("PENDING")
+
+	// ReconciliationError means that the transaction could not be fetched from MobileNig
+	ReconciliationError = ReconciliationStatus("ERROR")
+)
+
+// ExpectedTransaction is a local transaction which is reconciled
+type ExpectedTransaction struct {
+	TransactionID string
+	Amount        float64
+
+	// Status is the expected final status of the transaction. Defaults to LedgerStatusSucceeded.
+	Status LedgerStatus
+}
+
+// ExpectedTransactionsFromLedger returns the DStv payments in the store which have been sent to MobileNig.
+// Payments whose outcome is not known locally are expected to have succeeded.
+func ExpectedTransactionsFromLedger(ctx context.Context, store LedgerStore) ([]ExpectedTransaction, error) {
+	entries, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []ExpectedTransaction
+	for _, entry := range entries {
+		if entry.Operation != OperationPayDStv.String() || entry.Status == LedgerStatusQueued {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(entry.Request["price"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("mobilenig: invalid price [%s] in the ledger entry [%s]: %w", entry.Request["price"], entry.TransactionID, err)
+		}
+
+		transaction := ExpectedTransaction{TransactionID: entry.TransactionID, Amount: amount}
+		if entry.Status.IsTerminal() {
+			transaction.Status = entry.Status
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+// ReadExpectedTransactionsCSV reads transactions from a CSV file with the columns trans_id, amount and an optional status.
+// The status is a MobileNig transaction status e.g. SUCCESSFUL or FAILED, or the terminal LedgerStatus SUCCEEDED.
+func ReadExpectedTransactionsCSV(reader io.Reader) ([]ExpectedTransaction, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("mobilenig: cannot read the CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"trans_id", "amount"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("mobilenig: the CSV file has no [%s] column", name)
+		}
+	}
+
+	var transactions []ExpectedTransaction
+	for line := 2; ; line++ {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return transactions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[columns["amount"]]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("mobilenig: invalid amount on line %d: %w", line, err)
+		}
+
+		transaction := ExpectedTransaction{TransactionID: strings.TrimSpace(record[columns["trans_id"]]), Amount: amount}
+		if index, ok := columns["status"]; ok && index < len(record) {
+			status := strings.ToUpper(strings.TrimSpace(record[index]))
+			switch {
+			case status == "":
+			case LedgerStatus(status) == LedgerStatusSucceeded:
+				transaction.Status = LedgerStatusSucceeded
+			case TransactionLedgerStatus(status).IsTerminal():
+				transaction.Status = TransactionLedgerStatus(status)
+			default:
+				return nil, fmt.Errorf("mobilenig: invalid status [%s] on line %d", record[index], line)
+			}
+		}
+		transactions = append(transactions, transaction)
+	}
+}
+
+// ReconciliationEntry is the result of reconciling a transaction
+type ReconciliationEntry struct {
+	TransactionID  string               `json:"trans_id"`
+	Status         ReconciliationStatus `json:"status"`
+	ExpectedAmount float64              `json:"expected_amount"`
+	RemoteAmount   *float64             `json:"remote_amount,omitempty"`
+	ExpectedStatus LedgerStatus         `json:"expected_status"`
+	RemoteStatus   string               `json:"remote_status,omitempty"`
+	Error          string               `json:"error,omitempty"`
+}
+
+// ReconciliationReport is the result of reconciling a set of transactions
+type ReconciliationReport struct {
+	GeneratedAt time.Time                    `json:"generated_at"`
+	Summary     map[ReconciliationStatus]int `json:"summary"`
+	Entries     []ReconciliationEntry        `json:"entries"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (report *ReconciliationReport) WriteJSON(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteCSV writes the entries of the report as CSV
+func (report *ReconciliationReport) WriteCSV(writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	_ = csvWriter.Write([]string{"trans_id", "status", "expected_amount", "remote_amount", "expected_status", "remote_status", "error"})
+
+	for _, entry := range report.Entries {
+		remoteAmount := ""
+		if entry.RemoteAmount != nil {
+			remoteAmount = strconv.FormatFloat(*entry.RemoteAmount, 'f', -1, 64)
+		}
+
+		_ = csvWriter.Write([]string{
+			entry.TransactionID,
+			string(entry.Status),
+			strconv.FormatFloat(entry.ExpectedAmount, 'f', -1, 64),
+			remoteAmount,
+			entry.ExpectedStatus.String(),
+			entry.RemoteStatus,
+			entry.Error,
+		})
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// ReconcilerOption are options for constructing a Reconciler
+type ReconcilerOption interface {
+	apply(reconciler *Reconciler)
+}
+
+type reconcilerOptionFunc func(reconciler *Reconciler)
+
+func (fn reconcilerOptionFunc) apply(reconciler *Reconciler) {
+	fn(reconciler)
+}
+
+// WithReconcilerConcurrency sets the number of transactions which are fetched at the same time. Defaults to 4.
+func WithReconcilerConcurrency(concurrency int) ReconcilerOption {
+	return reconcilerOptionFunc(func(reconciler *Reconciler) {
+		if concurrency > 0 {
+			reconciler.concurrency = concurrency
+		}
+	})
+}
+
+// WithReconcilerNotFoundFunc sets the function which decides if the QueryDStv result means that MobileNig has no
+// transaction with the transaction ID. By default, only the transaction not found error is treated as not found, see
+// IsTransactionNotFound.
+func WithReconcilerNotFoundFunc(isNotFound func(resp *Response, err error) bool) ReconcilerOption {
+	return reconcilerOptionFunc(func(reconciler *Reconciler) {
+		if isNotFound != nil {
+			reconciler.isNotFound = isNotFound
+		}
+	})
+}
+
+// Reconciler compares local transactions with MobileNig's view of them using QueryDStv
+type Reconciler struct {
+	bills       BillsAPI
+	concurrency int
+	isNotFound  func(resp *Response, err error) bool
+}
+
+// NewReconciler creates a Reconciler which fetches transactions with bills e.g. client.Bills
+func NewReconciler(bills BillsAPI, options ...ReconcilerOption) *Reconciler {
+	reconciler := &Reconciler{
+		bills:       bills,
+		concurrency: 4,
+		isNotFound:  IsTransactionNotFound,
+	}
+
+	for _, option := range options {
+		option.apply(reconciler)
+	}
+
+	return reconciler
+}
+
+// Reconcile fetches every transaction from MobileNig and classifies it.
+// The entries of the report are in the same order as transactions. An error is returned only if ctx is done.
+func (reconciler *Reconciler) Reconcile(ctx context.Context, transactions []ExpectedTransaction) (*ReconciliationReport, error) {
+	entries := make([]ReconciliationEntry, len(transactions))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < reconciler.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				entries[index] = reconciler.reconcile(ctx, transactions[index])
+			}
+		}()
+	}
+
+	for index := range transactions {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &ReconciliationReport{
+		GeneratedAt: time.Now().UTC(),
+		Summary:     make(map[ReconciliationStatus]int),
+		Entries:     entries,
+	}
+	for _, entry := range entries {
+		report.Summary[entry.Status]++
+	}
+
+	return report, nil
+}
+
+// reconcile fetches and classifies a single transaction
+func (reconciler *Reconciler) reconcile(ctx context.Context, expected ExpectedTransaction) ReconciliationEntry {
+	entry := ReconciliationEntry{
+		TransactionID:  expected.TransactionID,
+		ExpectedAmount: expected.Amount,
+		ExpectedStatus: expected.Status,
+	}
+	if entry.ExpectedStatus == "" {
+		entry.ExpectedStatus = LedgerStatusSucceeded
+	}
+
+	transaction, resp, err := reconciler.bills.QueryDStv(ctx, expected.TransactionID)
+	switch {
+	case err != nil && reconciler.isNotFound(resp, err) && entry.ExpectedStatus == LedgerStatusFailed:
+		// A failed payment which never reached MobileNig is the expected outcome
+		entry.Status = ReconciliationMatched
+		return entry
+	case err != nil && reconciler.isNotFound(resp, err):
+		entry.Status = ReconciliationMissingRemotely
+		entry.Error = err.Error()
+		return entry
+	case err != nil:
+		entry.Status = ReconciliationError
+		entry.Error = err.Error()
+		return entry
+	}
+
+	entry.RemoteStatus = transaction.Details.Status
+	if amount, err := strconv.ParseFloat(transaction.Details.Price, 64); err == nil {
+		entry.RemoteAmount = &amount
+	}
+
+	remoteStatus := TransactionLedgerStatus(transaction.Details.Status)
+	switch {
+	case remoteStatus == LedgerStatusPending:
+		entry.Status = ReconciliationPending
+	case entry.RemoteAmount == nil || math.Abs(*entry.RemoteAmount-expected.Amount) >= 0.005:
+		entry.Status = ReconciliationAmountMismatch
+	case remoteStatus != entry.ExpectedStatus:
+		entry.Status = ReconciliationStatusMismatch
+	default:
+		entry.Status = ReconciliationMatched
+	}
+
+	return entry
+}
@@ -0,0 +1,122 @@
+package mobilenig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reconcileTestBills is a BillsAPI which returns the transactions in a map
+type reconcileTestBills struct {
+	BillsAPI
+	transactions map[string]*DStvTransaction
+}
+
+func (bills *reconcileTestBills) QueryDStv(_ context.Context, transactionID string) (*DStvTransaction, *Response, error) {
+	if transactionID == "error" {
+		return nil, nil, errors.New("connection reset")
+	}
+
+	if transactionID == "unauthorized" {
+		resp := &Response{Error: &ErrorResponse{Code: ErrorCodeInvalidCredentials, Description: "Invalid API key"}}
+		return nil, resp, errors.New("Invalid API key")
+	}
+
+	transaction, ok := bills.transactions[transactionID]
+	if !ok {
+		resp := &Response{Error: &ErrorResponse{Code: "ERR105", Description: "Transaction not found"}}
+		return nil, resp, errors.New("Transaction not found")
+	}
+	return transaction, &Response{}, nil
+}
+
+func newReconcileTestTransaction(transactionID string, price string, status string) *DStvTransaction {
+	transaction := &DStvTransaction{TransactionID: transactionID}
+	transaction.Details.Price = price
+	transaction.Details.Status = status
+	return transaction
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	// Arrange
+	bills := &reconcileTestBills{transactions: map[string]*DStvTransaction{
+		"matched":  newReconcileTestTransaction("matched", "2000", "SUCCESSFUL"),
+		"amount":   newReconcileTestTransaction("amount", "2500", "SUCCESSFUL"),
+		"status":   newReconcileTestTransaction("status", "2000", "FAILED"),
+		"pending":  newReconcileTestTransaction("pending", "2000", "PENDING"),
+		"failures": newReconcileTestTransaction("failures", "2000", "FAILED"),
+	}}
+
+	transactions, err := ReadExpectedTransactionsCSV(strings.NewReader(
+		"trans_id,amount,status\nmatched,2000,\namount,2000,\nstatus,2000,\npending,2000,\nmissing,2000,\nerror,2000,\nunauthorized,2000,\nfailures,2000,failed\nnever-sent,2000,FAILED\n",
+	))
+	assert.NoError(t, err)
+
+	// Act
+	report, err := NewReconciler(bills, WithReconcilerConcurrency(2), WithReconcilerNotFoundFunc(nil)).Reconcile(context.Background(), transactions)
+
+	// Assert
+	assert.NoError(t, err)
+
+	var statuses []ReconciliationStatus
+	for _, entry := range report.Entries {
+		statuses = append(statuses, entry.Status)
+	}
+	assert.Equal(t, []ReconciliationStatus{
+		ReconciliationMatched,
+		ReconciliationAmountMismatch,
+		ReconciliationStatusMismatch,
+		ReconciliationPending,
+		ReconciliationMissingRemotely,
+		ReconciliationError,
+		ReconciliationError,
+		ReconciliationMatched,
+		ReconciliationMatched,
+	}, statuses)
+	assert.Equal(t, 3, report.Summary[ReconciliationMatched])
+
+	csv := new(bytes.Buffer)
+	assert.NoError(t, report.WriteCSV(csv))
+	assert.Contains(t, csv.String(), "amount,AMOUNT_MISMATCH,2000,2500,SUCCEEDED,SUCCESSFUL,\n")
+
+	json := new(bytes.Buffer)
+	assert.NoError(t, report.WriteJSON(json))
+	assert.Contains(t, json.String(), `"MISSING_REMOTELY": 1`)
+}
+
+func TestReadExpectedTransactionsCSV_InvalidStatus(t *testing.T) {
+	// Act
+	_, err := ReadExpectedTransactionsCSV(strings.NewReader("trans_id,amount,status\nfirst,2000,SUCCESSFUL\nsecond,2000,DONE\n"))
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid status [DONE] on line 3")
+}
+
+func TestExpectedTransactionsFromLedger(t *testing.T) {
+	// Arrange
+	store := NewMemoryLedgerStore()
+	now := time.Now()
+	for _, entry := range []*LedgerEntry{
+		newTestLedgerEntry("queued", LedgerStatusQueued, now),
+		newTestLedgerEntry("pending", LedgerStatusPending, now.Add(time.Second)),
+		newTestLedgerEntry("failed", LedgerStatusFailed, now.Add(2*time.Second)),
+	} {
+		assert.NoError(t, store.Save(context.Background(), entry))
+	}
+
+	// Act
+	transactions, err := ExpectedTransactionsFromLedger(context.Background(), store)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(transactions))
+	assert.Equal(t, "pending", transactions[0].TransactionID)
+	assert.Equal(t, LedgerStatus(""), transactions[0].Status)
+	assert.Equal(t, LedgerStatusFailed, transactions[1].Status)
+}
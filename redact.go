@@ -0,0 +1,34 @@
+package mobilenig
+
+import (
+	"net/url"
+)
+
+// redacted replaces secret values
+const redacted = "REDACTED"
+
+// secretParams are the query parameters which contain secrets
+var secretParams = []string{"api_key"}
+
+// redactParams returns a copy of the query parameters with the values of keys replaced by redacted
+func redactParams(values url.Values, keys ...string) url.Values {
+	clone := make(url.Values, len(values))
+	for key, value := range values {
+		clone[key] = append([]string(nil), value...)
+	}
+
+	for _, key := range keys {
+		if _, ok := clone[key]; ok {
+			clone.Set(key, redacted)
+		}
+	}
+
+	return clone
+}
+
+// redactURL returns the URL with the values of the query parameters in keys replaced by redacted
+func redactURL(requestURL *url.URL, keys ...string) string {
+	clone := *requestURL
+	clone.RawQuery = redactParams(requestURL.Query(), keys...).Encode()
+	return clone.String()
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 // ErrorResponse is the response that is returned when there is an API error
@@ -13,11 +14,36 @@ type ErrorResponse struct {
 	Description string `json:"description"`
 }
 
+// ErrorCodeInvalidCredentials is the error code returned when the username or API key is invalid
+const ErrorCodeInvalidCredentials = "ERR101"
+
+// ErrorCodeInsufficientBalance is the error code returned when the wallet balance is less than the price of a payment
+const ErrorCodeInsufficientBalance = "ERR102"
+
+// ErrorCodeTransactionNotFound is the error code returned when there is no transaction with the transaction ID
+const ErrorCodeTransactionNotFound = "ERR105"
+
+// IsTransactionNotFound returns true when the result of QueryDStv means that MobileNig has no transaction with the
+// transaction ID. Any other error e.g. invalid credentials or maintenance does not tell whether the payment exists.
+func IsTransactionNotFound(resp *Response, err error) bool {
+	if err == nil || resp == nil || resp.Error == nil {
+		return false
+	}
+	return resp.Error.Code == ErrorCodeTransactionNotFound ||
+		strings.EqualFold(strings.TrimSpace(resp.Error.Description), "Transaction not found")
+}
+
 // Response captures the http response
 type Response struct {
 	HTTPResponse *http.Response
 	Body         *[]byte
 	Error        *ErrorResponse
+
+	// DryRun is the request which would have been sent when a payment is made in dry-run mode
+	DryRun *DryRunResult
+
+	// Warnings are the differences between the body and the schema expected by the client in strict decoding mode
+	Warnings []SchemaWarning
 }
 
 // Err returns an error if the http request is not successfull
@@ -38,3 +64,27 @@ func (r *Response) errorMessage() string {
 
 	return buf.String()
 }
+
+// clone returns a copy of the response which does not share the body or error with r
+func (r *Response) clone() *Response {
+	if r == nil {
+		return nil
+	}
+
+	resp := &Response{HTTPResponse: r.HTTPResponse, DryRun: r.DryRun}
+	if r.Warnings != nil {
+		resp.Warnings = append([]SchemaWarning(nil), r.Warnings...)
+	}
+	if r.Body != nil {
+		body := make([]byte, len(*r.Body))
+		copy(body, *r.Body)
+		resp.Body = &body
+	}
+
+	if r.Error != nil {
+		errorResponse := *r.Error
+		resp.Error = &errorResponse
+	}
+
+	return resp
+}
@@ -0,0 +1,98 @@
+package mobilenig
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryPolicy configures how lookups are retried when a request fails with a network error or a 5xx/429 status code.
+// Payments are never retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a request is retried
+	MaxRetries int
+
+	// Backoff is the delay before the first retry, it is doubled for every subsequent retry
+	Backoff time.Duration
+}
+
+// shouldRetry returns true when a lookup can be retried
+func shouldRetry(resp *Response, err error) bool {
+	if isContextError(err) {
+		return false
+	}
+
+	if resp == nil {
+		return err != nil
+	}
+
+	status := resp.HTTPResponse.StatusCode
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// wait blocks until the backoff of the retry attempt has passed or the context is cancelled
+func (policy *RetryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(policy.Backoff << uint(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimiter is a token bucket which limits the number of requests per second
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+func newRateLimiter(requestsPerSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until a request can be made or the context is cancelled
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+	limiter.mu.Lock()
+	now := time.Now()
+	limiter.tokens += float64(now.Sub(limiter.last)) / float64(limiter.interval)
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.last = now
+	limiter.tokens--
+	delay := time.Duration(-limiter.tokens * float64(limiter.interval))
+	limiter.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		limiter.mu.Lock()
+		limiter.tokens++
+		limiter.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,95 @@
+package mobilenig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeFlakyTestServer creates a server which returns 503 for the first failures requests
+func makeFlakyTestServer(failures int32, body string, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(requests, 1) <= failures {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = res.Write([]byte(body))
+	}))
+}
+
+func TestClient_RetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("lookups are retried", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		var requests int32
+		server := makeFlakyTestServer(2, stubs.QueryDstvTransactionResponse(), &requests)
+		baseURL, _ := url.Parse(server.URL)
+		client := New(WithBaseURL(baseURL), WithRetryPolicy(RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}))
+
+		// Act
+		transaction, _, err := client.Bills.QueryDStv(context.Background(), "122790223")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "122790223", transaction.TransactionID)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("payments are not retried", func(t *testing.T) {
+		t.Parallel()
+
+		// Arrange
+		var requests int32
+		server := makeFlakyTestServer(1, stubs.PayDstvBillResponse(), &requests)
+		baseURL, _ := url.Parse(server.URL)
+		client := New(WithBaseURL(baseURL), WithRetryPolicy(RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}))
+
+		// Act
+		_, resp, _ := client.Bills.PayDStv(context.Background(), &PayDstvOptions{})
+
+		// Assert
+		assert.Equal(t, http.StatusServiceUnavailable, resp.HTTPResponse.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+		// Teardown
+		server.Close()
+	})
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var requests int32
+	server := makeFlakyTestServer(0, stubs.CheckDstvUserResponse(), &requests)
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL), WithRateLimit(20, 1))
+	start := time.Now()
+
+	// Act
+	for i := 0; i < 4; i++ {
+		_, _, err := client.Bills.CheckDStvUser(context.Background(), "4131953321")
+		assert.NoError(t, err)
+	}
+
+	// Assert
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(140*time.Millisecond))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	// Teardown
+	server.Close()
+}
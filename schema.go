@@ -0,0 +1,151 @@
+package mobilenig
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SchemaWarningKind is the type of difference between a MobileNig response and the schema expected by the client
+type SchemaWarningKind string
+
+const (
+	// SchemaWarningUnexpectedField is a field in the response which is not decoded by the client
+	SchemaWarningUnexpectedField = SchemaWarningKind("UNEXPECTED_FIELD")
+
+	// SchemaWarningMissingField is a field expected by the client which is not in the response
+	SchemaWarningMissingField = SchemaWarningKind("MISSING_FIELD")
+)
+
+// SchemaWarning is a difference between a MobileNig response and the schema expected by the client
+type SchemaWarning struct {
+	Operation Operation
+	Kind      SchemaWarningKind
+
+	// Path is the dotted path of the field e.g "details.status". Array indexes are written as "[]".
+	Path string
+}
+
+// String returns a description of the warning
+func (warning SchemaWarning) String() string {
+	return string(warning.Operation) + ": " + string(warning.Kind) + " [" + warning.Path + "]"
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// decode unmarshals the body of the response into value.
+// In strict decoding mode, the differences between the body and the type of value are added to Response.Warnings.
+func (client *Client) decode(operation Operation, resp *Response, value interface{}) error {
+	if err := json.Unmarshal(*resp.Body, value); err != nil {
+		return err
+	}
+
+	if !client.strictDecoding {
+		return nil
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(*resp.Body, &payload); err != nil {
+		return err
+	}
+
+	warnings := compareSchema(operation, "", payload, reflect.TypeOf(value))
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].Path < warnings[j].Path })
+	resp.Warnings = append(resp.Warnings, warnings...)
+
+	if client.onSchemaWarning != nil {
+		client.onSchemaWarning(warnings)
+	}
+
+	return nil
+}
+
+// compareSchema returns the fields of payload which are not in the json tags of the type and the tagged fields which
+// are missing in payload. Fields tagged with omitempty are optional.
+func compareSchema(operation Operation, path string, payload interface{}, valueType reflect.Type) []SchemaWarning {
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	if reflect.PtrTo(valueType).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+
+	var warnings []SchemaWarning
+	switch valueType.Kind() {
+	case reflect.Struct:
+		object, ok := payload.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		fields := make(map[string]bool)
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+			name, optional := jsonFieldName(field)
+			if name == "" {
+				continue
+			}
+			fields[name] = true
+
+			fieldValue, ok := object[name]
+			if !ok {
+				if !optional {
+					warnings = append(warnings, SchemaWarning{Operation: operation, Kind: SchemaWarningMissingField, Path: joinSchemaPath(path, name)})
+				}
+				continue
+			}
+			warnings = append(warnings, compareSchema(operation, joinSchemaPath(path, name), fieldValue, field.Type)...)
+		}
+
+		for name := range object {
+			if !fields[name] {
+				warnings = append(warnings, SchemaWarning{Operation: operation, Kind: SchemaWarningUnexpectedField, Path: joinSchemaPath(path, name)})
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		items, _ := payload.([]interface{})
+		for _, item := range items {
+			warnings = append(warnings, compareSchema(operation, path+"[]", item, valueType.Elem())...)
+		}
+	}
+
+	return warnings
+}
+
+// jsonFieldName returns the name of a struct field in JSON and whether it is tagged with omitempty
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+func joinSchemaPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,77 @@
+package mobilenig
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/helpers"
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_StrictDecoding(t *testing.T) {
+	t.Run("documented responses have no warnings", func(t *testing.T) {
+		// Arrange
+		server := helpers.MakeTestServer(http.StatusOK, stubs.CheckDstvUserResponse())
+		baseURL, _ := url.Parse(server.URL)
+		client := New(WithBaseURL(baseURL), WithStrictDecoding(nil))
+
+		// Act
+		_, resp, err := client.Bills.CheckDStvUser(context.Background(), "4131953321")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Empty(t, resp.Warnings)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("unexpected and missing fields are reported", func(t *testing.T) {
+		// Arrange
+		server := helpers.MakeTestServer(http.StatusOK, `{"trans_id":"122790223","details":{"service":"DSTV","package":"DStv Compact","smartno":"4131953321","price":"790","state":"SUCCESSFUL","balance":"7931"}}`)
+		baseURL, _ := url.Parse(server.URL)
+
+		var reported []SchemaWarning
+		client := New(WithBaseURL(baseURL), WithStrictDecoding(func(warnings []SchemaWarning) {
+			reported = append(reported, warnings...)
+		}))
+
+		// Act
+		transaction, resp, err := client.Bills.QueryDStv(context.Background(), "122790223")
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "DStv Compact", transaction.Details.Package)
+
+		expected := []SchemaWarning{
+			{Operation: OperationQueryDStv, Kind: SchemaWarningUnexpectedField, Path: "details.state"},
+			{Operation: OperationQueryDStv, Kind: SchemaWarningMissingField, Path: "details.status"},
+		}
+		assert.Equal(t, expected, resp.Warnings)
+		assert.Equal(t, expected, reported)
+
+		// Teardown
+		server.Close()
+	})
+
+	t.Run("responses are not compared without strict decoding", func(t *testing.T) {
+		// Arrange
+		server := helpers.MakeTestServer(http.StatusOK, `{"packageName":"DStv Compact","bouquet":"COMPE36"}`)
+		baseURL, _ := url.Parse(server.URL)
+		client := New(WithBaseURL(baseURL))
+
+		// Act
+		dstvPackage, resp, err := client.Bills.GetDStvPackage(context.Background(), 275953782)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "DStv Compact", *dstvPackage)
+		assert.Empty(t, resp.Warnings)
+
+		// Teardown
+		server.Close()
+	})
+}
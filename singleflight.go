@@ -0,0 +1,66 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// singleflightCall is an in-flight or completed singleflightGroup.do call
+type singleflightCall struct {
+	done  chan struct{}
+	value interface{}
+	resp  *Response
+	err   error
+}
+
+// singleflightGroup deduplicates concurrent calls which share the same key so that only one of them is executed.
+type singleflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*singleflightCall
+}
+
+// do executes fn once for all concurrent callers with the same key.
+// The bool return value reports whether the result was produced by another caller's fn.
+// Callers which receive a shared result must clone it before handing it out.
+func (group *singleflightGroup) do(ctx context.Context, key string, fn func() (interface{}, *Response, error)) (interface{}, *Response, bool, error) {
+	group.mu.Lock()
+	if group.calls == nil {
+		group.calls = make(map[string]*singleflightCall)
+	}
+
+	if call, ok := group.calls[key]; ok {
+		group.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, nil, false, ctx.Err()
+		}
+
+		// The context of the caller which made the request was cancelled, but ours is still valid.
+		if isContextError(call.err) && ctx.Err() == nil {
+			value, resp, err := fn()
+			return value, resp, false, err
+		}
+
+		return call.value, call.resp, true, call.err
+	}
+
+	call := &singleflightCall{done: make(chan struct{})}
+	group.calls[key] = call
+	group.mu.Unlock()
+
+	call.value, call.resp, call.err = fn()
+
+	group.mu.Lock()
+	delete(group.calls, key)
+	group.mu.Unlock()
+	close(call.done)
+
+	return call.value, call.resp, false, call.err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,139 @@
+package mobilenig
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrDuplicateTransactionID is returned when a transaction ID has already been sent by the client
+var ErrDuplicateTransactionID = errors.New("mobilenig: transaction ID has already been used")
+
+// TransactionIDGenerator generates unique transaction IDs for payments e.g PayDstvOptions.TransactionID
+type TransactionIDGenerator interface {
+	// NewTransactionID returns a new unique transaction ID
+	NewTransactionID() (string, error)
+}
+
+// TransactionIDStore keeps track of the transaction IDs which have been sent by the client
+type TransactionIDStore interface {
+	// Reserve marks the transaction ID as used.
+	// It returns ErrDuplicateTransactionID if the transaction ID has already been reserved.
+	Reserve(ctx context.Context, transactionID string) error
+
+	// Release removes a reserved transaction ID e.g. when the payment was not sent, so that it can be used again
+	Release(ctx context.Context, transactionID string) error
+}
+
+// crockford is the Crockford's base32 alphabet used to encode transaction IDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator generates 26 character ULIDs (https://github.com/ulid/spec).
+// The first 48 bits are the unix time in milliseconds so IDs are sortable by time and the remaining 80 bits are random
+// so IDs generated by different processes or machines don't collide.
+// IDs generated by the same generator within the same millisecond are monotonically increasing.
+type ulidGenerator struct {
+	mu       sync.Mutex
+	entropy  io.Reader
+	now      func() time.Time
+	lastTime uint64
+	lastRand [10]byte
+}
+
+// NewTransactionIDGenerator creates the default TransactionIDGenerator which generates time sortable ULIDs
+func NewTransactionIDGenerator() TransactionIDGenerator {
+	return &ulidGenerator{entropy: rand.Reader, now: time.Now}
+}
+
+// NewTransactionID returns a new ULID
+func (generator *ulidGenerator) NewTransactionID() (string, error) {
+	generator.mu.Lock()
+	defer generator.mu.Unlock()
+
+	timestamp := uint64(generator.now().UnixNano() / int64(time.Millisecond))
+	if timestamp <= generator.lastTime {
+		timestamp = generator.lastTime
+		if !incrementBytes(generator.lastRand[:]) {
+			return "", errors.New("mobilenig: transaction ID entropy exhausted for the current millisecond")
+		}
+	} else if _, err := io.ReadFull(generator.entropy, generator.lastRand[:]); err != nil {
+		return "", err
+	}
+	generator.lastTime = timestamp
+
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(timestamp >> (40 - 8*uint(i)))
+	}
+	copy(id[6:], generator.lastRand[:])
+
+	return encodeCrockford(id), nil
+}
+
+// incrementBytes increments a big endian number in place. It returns false when the number overflows.
+func incrementBytes(number []byte) bool {
+	for i := len(number) - 1; i >= 0; i-- {
+		number[i]++
+		if number[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCrockford encodes 128 bits as 26 base32 characters
+func encodeCrockford(id [16]byte) string {
+	result := make([]byte, 26)
+
+	// 130 bits are needed for 26 characters, so the first character only encodes the top 3 bits.
+	var buffer uint64
+	bits := uint(2)
+	position := 0
+	for _, b := range id {
+		buffer = buffer<<8 | uint64(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			result[position] = crockford[(buffer>>bits)&31]
+			position++
+		}
+	}
+
+	return string(result)
+}
+
+// memoryTransactionIDStore is a TransactionIDStore which lives for the lifetime of the process
+type memoryTransactionIDStore struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+// NewMemoryTransactionIDStore creates an in-memory TransactionIDStore
+func NewMemoryTransactionIDStore() TransactionIDStore {
+	return &memoryTransactionIDStore{ids: make(map[string]struct{})}
+}
+
+// Reserve marks the transaction ID as used
+func (store *memoryTransactionIDStore) Reserve(_ context.Context, transactionID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.ids[transactionID]; ok {
+		return ErrDuplicateTransactionID
+	}
+
+	store.ids[transactionID] = struct{}{}
+	return nil
+}
+
+// Release removes the transaction ID
+func (store *memoryTransactionIDStore) Release(_ context.Context, transactionID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.ids, transactionID)
+	return nil
+}
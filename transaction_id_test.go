@@ -0,0 +1,78 @@
+package mobilenig
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTransactionIDGenerator(t *testing.T) {
+	t.Run("it generates 26 character crockford base32 IDs", func(t *testing.T) {
+		// Arrange
+		generator := NewTransactionIDGenerator()
+
+		// Act
+		transactionID, err := generator.NewTransactionID()
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, transactionID, 26)
+		for _, char := range transactionID {
+			assert.Contains(t, crockford, string(char))
+		}
+	})
+
+	t.Run("it generates unique IDs which are sorted by time", func(t *testing.T) {
+		// Arrange
+		generator := NewTransactionIDGenerator()
+		ids := make([]string, 1000)
+
+		// Act
+		for i := range ids {
+			id, err := generator.NewTransactionID()
+			assert.NoError(t, err)
+			ids[i] = id
+		}
+
+		// Assert
+		assert.True(t, sort.StringsAreSorted(ids))
+
+		unique := map[string]bool{}
+		for _, id := range ids {
+			unique[id] = true
+		}
+		assert.Len(t, unique, len(ids))
+	})
+
+	t.Run("it encodes the timestamp in the first 10 characters", func(t *testing.T) {
+		// Arrange
+		generator := &ulidGenerator{
+			entropy: bytes.NewReader(make([]byte, 10)),
+			now:     func() time.Time { return time.Unix(1469918176, 385000000) },
+		}
+
+		// Act
+		transactionID, err := generator.NewTransactionID()
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "01ARYZ6S410000000000000000", transactionID)
+	})
+}
+
+func TestNewMemoryTransactionIDStore(t *testing.T) {
+	// Arrange
+	store := NewMemoryTransactionIDStore()
+
+	// Act
+	first := store.Reserve(context.Background(), "122790223")
+	second := store.Reserve(context.Background(), "122790223")
+
+	// Assert
+	assert.NoError(t, first)
+	assert.Equal(t, ErrDuplicateTransactionID, second)
+}
@@ -0,0 +1,45 @@
+package mobilenig
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// ErrInsufficientBalance is returned when the wallet balance is too low to make a payment
+var ErrInsufficientBalance = errors.New("mobilenig: insufficient wallet balance")
+
+// WalletService is the API client for the `/balance` endpoint
+type WalletService service
+
+// WalletBalance is the balance of the MobileNig wallet
+type WalletBalance struct {
+	Balance string `json:"balance"`
+}
+
+// Amount returns the balance as a number
+func (balance *WalletBalance) Amount() (float64, error) {
+	return strconv.ParseFloat(balance.Balance, 64)
+}
+
+// GetBalance returns the balance of the MobileNig wallet
+// GET /balance
+// API Doc: https://mobilenig.com/API/docs/balance
+func (service *WalletService) GetBalance(ctx context.Context) (*WalletBalance, *Response, error) {
+	request, err := service.client.newRequest(ctx, endpointGetBalance, map[string]string{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := service.client.do(request)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var balance WalletBalance
+	if err = service.client.decode(OperationGetBalance, resp, &balance); err != nil {
+		return nil, resp, err
+	}
+
+	return &balance, resp, nil
+}
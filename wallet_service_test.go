@@ -0,0 +1,78 @@
+package mobilenig
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/helpers"
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_GetBalance_ResponseConstructedCorrectly(t *testing.T) {
+	// Setup
+	t.Parallel()
+	server := helpers.MakeTestServer(http.StatusOK, stubs.WalletBalanceResponse())
+
+	// Arrange
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL))
+
+	// Act
+	balance, _, err := client.Wallet.GetBalance(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "7931", balance.Balance)
+
+	amount, err := balance.Amount()
+	assert.NoError(t, err)
+	assert.Equal(t, 7931.0, amount)
+
+	// Teardown
+	server.Close()
+}
+
+func TestWalletService_GetBalance_RequestConstructedCorrectly(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	request := new(http.Request)
+	server := helpers.MakeRequestCapturingTestServer(http.StatusOK, stubs.WalletBalanceResponse(), request)
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL), WithAPIKey(testAPIKey), WithUsername(testUsername))
+
+	// Act
+	_, _, _ = client.Wallet.GetBalance(context.Background())
+
+	// Assert
+	assert.Equal(t, "/balance", request.URL.Path)
+	assert.Equal(t, testUsername, request.URL.Query().Get("username"))
+	assert.Equal(t, testAPIKey, request.URL.Query().Get("api_key"))
+
+	// Teardown
+	server.Close()
+}
+
+func TestWalletService_GetBalance_ErrorResponseConstructedCorrectly(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	server := helpers.MakeTestServer(http.StatusOK, stubs.ErrorResponse())
+	baseURL, _ := url.Parse(server.URL)
+	client := New(WithBaseURL(baseURL))
+
+	// Act
+	_, resp, err := client.Wallet.GetBalance(context.Background())
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, "ERR101", resp.Error.Code)
+
+	// Teardown
+	server.Close()
+}
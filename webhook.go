@@ -0,0 +1,430 @@
+package mobilenig
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader is the header with the hex encoded HMAC-SHA256 of the notification body
+	WebhookSignatureHeader = "X-MobileNig-Signature"
+
+	// WebhookServiceDStv is the service of DStv notifications
+	WebhookServiceDStv = "DSTV"
+
+	// maxWebhookBodySize is the maximum size of a notification body
+	maxWebhookBodySize = 1 << 20
+
+	// webhookDeliveryLease is how long a delivery stays in progress in a memory store before it can be processed again
+	webhookDeliveryLease = 5 * time.Minute
+)
+
+var (
+	// ErrDuplicateWebhookDelivery is returned by a WebhookDeliveryStore when a delivery has already been processed
+	ErrDuplicateWebhookDelivery = errors.New("mobilenig: webhook delivery has already been processed")
+
+	// ErrWebhookDeliveryInProgress is returned by a WebhookDeliveryStore when a delivery is being processed
+	ErrWebhookDeliveryInProgress = errors.New("mobilenig: webhook delivery is being processed")
+
+	// ErrNoWebhookHandler is reported when a notification is received for a service without a handler
+	ErrNoWebhookHandler = errors.New("mobilenig: no webhook handler is registered for the service")
+)
+
+// WebhookNotification is an asynchronous transaction status notification sent by MobileNig
+type WebhookNotification struct {
+	// DeliveryID identifies the delivery with the service, transaction ID and status of the notification, so the same
+	// outcome is processed once. It is built from the body which is covered by the signature.
+	DeliveryID    string
+	TransactionID string
+	Service       string
+	Status        string
+	Body          json.RawMessage
+}
+
+// LedgerStatus returns the status of the transaction as a LedgerStatus
+func (notification *WebhookNotification) LedgerStatus() LedgerStatus {
+	return TransactionLedgerStatus(notification.Status)
+}
+
+// webhookPayload is the part of a notification which is common to all services
+type webhookPayload struct {
+	TransactionID string `json:"trans_id"`
+	Details       struct {
+		Service string `json:"service"`
+		Status  string `json:"status"`
+	} `json:"details"`
+}
+
+// WebhookDeliveryStore keeps track of the notification deliveries which are being processed or have been processed
+type WebhookDeliveryStore interface {
+	// Reserve marks the delivery as in progress. It returns ErrWebhookDeliveryInProgress if the delivery is in progress,
+	// and ErrDuplicateWebhookDelivery if it has been completed. A delivery which is in progress must eventually expire
+	// so that it is processed again if the process which reserved it crashed.
+	Reserve(ctx context.Context, deliveryID string) error
+
+	// Complete marks a reserved delivery as processed
+	Complete(ctx context.Context, deliveryID string) error
+
+	// Release removes the delivery so that it is processed again when it is retried by MobileNig
+	Release(ctx context.Context, deliveryID string) error
+}
+
+// webhookDelivery is the state of a delivery in a memoryWebhookDeliveryStore
+type webhookDelivery struct {
+	completed bool
+	expiresAt time.Time
+}
+
+// memoryWebhookDeliveryStore is a WebhookDeliveryStore which forgets completed deliveries after a TTL
+type memoryWebhookDeliveryStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	now        func() time.Time
+	deliveries map[string]*webhookDelivery
+}
+
+// NewMemoryWebhookDeliveryStore creates an in-memory WebhookDeliveryStore which remembers completed deliveries for ttl
+func NewMemoryWebhookDeliveryStore(ttl time.Duration) WebhookDeliveryStore {
+	return &memoryWebhookDeliveryStore{ttl: ttl, now: time.Now, deliveries: make(map[string]*webhookDelivery)}
+}
+
+// Reserve marks the delivery as in progress
+func (store *memoryWebhookDeliveryStore) Reserve(_ context.Context, deliveryID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.now()
+	for id, delivery := range store.deliveries {
+		if now.After(delivery.expiresAt) {
+			delete(store.deliveries, id)
+		}
+	}
+
+	if delivery, ok := store.deliveries[deliveryID]; ok {
+		if delivery.completed {
+			return ErrDuplicateWebhookDelivery
+		}
+		return ErrWebhookDeliveryInProgress
+	}
+
+	store.deliveries[deliveryID] = &webhookDelivery{expiresAt: now.Add(webhookDeliveryLease)}
+	return nil
+}
+
+// Complete marks the delivery as processed
+func (store *memoryWebhookDeliveryStore) Complete(_ context.Context, deliveryID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.deliveries[deliveryID] = &webhookDelivery{completed: true, expiresAt: store.now().Add(store.ttl)}
+	return nil
+}
+
+// Release removes the delivery
+func (store *memoryWebhookDeliveryStore) Release(_ context.Context, deliveryID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.deliveries, deliveryID)
+	return nil
+}
+
+// WebhookOption are options for constructing a WebhookHandler
+type WebhookOption interface {
+	apply(handler *WebhookHandler)
+}
+
+type webhookOptionFunc func(handler *WebhookHandler)
+
+func (fn webhookOptionFunc) apply(handler *WebhookHandler) {
+	fn(handler)
+}
+
+// WithWebhookSecret verifies that the WebhookSignatureHeader of every notification is the HMAC-SHA256 of the body
+// signed with secret
+func WithWebhookSecret(secret string) WebhookOption {
+	return webhookOptionFunc(func(handler *WebhookHandler) {
+		handler.secret = []byte(secret)
+	})
+}
+
+// WithWebhookIPAllowlist accepts notifications only from the IP addresses or CIDR ranges e.g. "41.203.0.0/16".
+// The IP address of the sender is the remote address of the connection, forwarded headers are not trusted.
+func WithWebhookIPAllowlist(addresses ...string) WebhookOption {
+	return webhookOptionFunc(func(handler *WebhookHandler) {
+		for _, address := range addresses {
+			if !strings.Contains(address, "/") {
+				if ip := net.ParseIP(address); ip != nil && ip.To4() != nil {
+					address += "/32"
+				} else {
+					address += "/128"
+				}
+			}
+
+			_, network, err := net.ParseCIDR(address)
+			if err != nil {
+				handler.optionErr = fmt.Errorf("mobilenig: invalid webhook IP allowlist entry [%s]: %w", address, err)
+				continue
+			}
+			handler.allowlist = append(handler.allowlist, network)
+		}
+	})
+}
+
+// WithWebhookDeliveryStore sets the store used to deduplicate deliveries.
+// By default, deliveries are remembered in memory for 24 hours.
+func WithWebhookDeliveryStore(store WebhookDeliveryStore) WebhookOption {
+	return webhookOptionFunc(func(handler *WebhookHandler) {
+		if store != nil {
+			handler.deliveries = store
+		}
+	})
+}
+
+// WithWebhookErrorHandler sets a function which is called when a notification is rejected or cannot be processed
+func WithWebhookErrorHandler(onError func(req *http.Request, err error)) WebhookOption {
+	return webhookOptionFunc(func(handler *WebhookHandler) {
+		handler.onError = onError
+	})
+}
+
+// WebhookHandler is an http.Handler which receives transaction status notifications from MobileNig, verifies the
+// sender, deduplicates deliveries and dispatches them to the registered handlers.
+//
+// A notification is acknowledged with 200 OK once every handler returned nil. When a handler returns an error, the
+// delivery is released and a 500 is returned so that MobileNig retries it.
+type WebhookHandler struct {
+	secret     []byte
+	allowlist  []*net.IPNet
+	deliveries WebhookDeliveryStore
+	onError    func(req *http.Request, err error)
+	optionErr  error
+
+	mu       sync.RWMutex
+	dstv     []func(ctx context.Context, transaction *DStvTransaction) error
+	services map[string][]func(ctx context.Context, notification *WebhookNotification) error
+}
+
+// NewWebhookHandler creates a WebhookHandler. WithWebhookSecret or WithWebhookIPAllowlist is required.
+func NewWebhookHandler(options ...WebhookOption) (*WebhookHandler, error) {
+	handler := &WebhookHandler{
+		deliveries: NewMemoryWebhookDeliveryStore(24 * time.Hour),
+		services:   make(map[string][]func(ctx context.Context, notification *WebhookNotification) error),
+	}
+
+	for _, option := range options {
+		option.apply(handler)
+	}
+
+	if handler.optionErr != nil {
+		return nil, handler.optionErr
+	}
+
+	if len(handler.secret) == 0 && len(handler.allowlist) == 0 {
+		return nil, errors.New("mobilenig: a webhook secret or IP allowlist is required to verify the sender")
+	}
+
+	return handler, nil
+}
+
+// HandleDStv registers a function which is called with the transaction of every DStv notification
+func (handler *WebhookHandler) HandleDStv(fn func(ctx context.Context, transaction *DStvTransaction) error) {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	handler.dstv = append(handler.dstv, fn)
+}
+
+// Handle registers a function which is called with every notification of the service e.g. "ELECTRICITY".
+// Services which have no typed transaction struct are handled with the raw notification.
+func (handler *WebhookHandler) Handle(service string, fn func(ctx context.Context, notification *WebhookNotification) error) {
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	service = strings.ToUpper(service)
+	handler.services[service] = append(handler.services[service], fn)
+}
+
+// ServeHTTP receives a notification. It responds with 200 once the notification has been processed or when it is a
+// duplicate of a processed delivery, and with a retryable status when the delivery is in progress or has failed.
+func (handler *WebhookHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		res.Header().Set("Allow", http.MethodPost)
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := handler.verifySender(req); err != nil {
+		handler.reject(res, req, http.StatusForbidden, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(res, req.Body, maxWebhookBodySize))
+	if err != nil {
+		handler.reject(res, req, http.StatusBadRequest, fmt.Errorf("mobilenig: cannot read the webhook body: %w", err))
+		return
+	}
+
+	if err = handler.verifySignature(req, body); err != nil {
+		handler.reject(res, req, http.StatusUnauthorized, err)
+		return
+	}
+
+	notification, err := parseWebhookNotification(body)
+	if err != nil {
+		handler.reject(res, req, http.StatusBadRequest, err)
+		return
+	}
+
+	// MobileNig retries the notification until a handler is registered for its service
+	if !handler.hasHandler(notification.Service) {
+		handler.reject(res, req, http.StatusServiceUnavailable, fmt.Errorf("%w: [%s]", ErrNoWebhookHandler, notification.Service))
+		return
+	}
+
+	err = handler.deliveries.Reserve(req.Context(), notification.DeliveryID)
+	switch {
+	case errors.Is(err, ErrDuplicateWebhookDelivery):
+		res.WriteHeader(http.StatusOK)
+		return
+	case errors.Is(err, ErrWebhookDeliveryInProgress):
+		// The delivery must be retried since it is lost if the delivery which is in progress fails
+		http.Error(res, http.StatusText(http.StatusConflict), http.StatusConflict)
+		return
+	case err != nil:
+		handler.reject(res, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = handler.dispatch(req.Context(), notification); err != nil {
+		_ = handler.deliveries.Release(context.Background(), notification.DeliveryID)
+		handler.reject(res, req, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The notification has been processed, so a failure to complete the delivery is only reported
+	if err = handler.deliveries.Complete(context.Background(), notification.DeliveryID); err != nil && handler.onError != nil {
+		handler.onError(req, err)
+	}
+
+	res.WriteHeader(http.StatusOK)
+}
+
+// hasHandler returns true when a handler is registered for the service
+func (handler *WebhookHandler) hasHandler(service string) bool {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+
+	return len(handler.services[service]) > 0 || (service == WebhookServiceDStv && len(handler.dstv) > 0)
+}
+
+// verifySender checks that the remote address is in the IP allowlist
+func (handler *WebhookHandler) verifySender(req *http.Request) error {
+	if len(handler.allowlist) == 0 {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range handler.allowlist {
+			if network.Contains(ip) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("mobilenig: webhook sender [%s] is not in the IP allowlist", host)
+}
+
+// verifySignature checks the WebhookSignatureHeader of the request
+func (handler *WebhookHandler) verifySignature(req *http.Request, body []byte) error {
+	if len(handler.secret) == 0 {
+		return nil
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(req.Header.Get(WebhookSignatureHeader), "sha256="))
+	if err != nil || len(signature) == 0 {
+		return errors.New("mobilenig: the webhook signature is missing or malformed")
+	}
+
+	mac := hmac.New(sha256.New, handler.secret)
+	_, _ = mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("mobilenig: the webhook signature is invalid")
+	}
+
+	return nil
+}
+
+// dispatch calls the handlers registered for the service of the notification
+func (handler *WebhookHandler) dispatch(ctx context.Context, notification *WebhookNotification) error {
+	handler.mu.RLock()
+	dstv := handler.dstv
+	services := handler.services[notification.Service]
+	handler.mu.RUnlock()
+
+	if notification.Service == WebhookServiceDStv && len(dstv) > 0 {
+		transaction := new(DStvTransaction)
+		if err := json.Unmarshal(notification.Body, transaction); err != nil {
+			return fmt.Errorf("mobilenig: cannot decode the DStv notification [%s]: %w", notification.TransactionID, err)
+		}
+
+		for _, fn := range dstv {
+			if err := fn(ctx, transaction); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, fn := range services {
+		if err := fn(ctx, notification); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (handler *WebhookHandler) reject(res http.ResponseWriter, req *http.Request, statusCode int, err error) {
+	if handler.onError != nil {
+		handler.onError(req, err)
+	}
+	http.Error(res, http.StatusText(statusCode), statusCode)
+}
+
+// parseWebhookNotification decodes the fields which are common to all services
+func parseWebhookNotification(body []byte) (*WebhookNotification, error) {
+	payload := new(webhookPayload)
+	if err := json.Unmarshal(body, payload); err != nil {
+		return nil, fmt.Errorf("mobilenig: cannot decode the webhook notification: %w", err)
+	}
+
+	if payload.TransactionID == "" || payload.Details.Service == "" || payload.Details.Status == "" {
+		return nil, errors.New("mobilenig: the webhook notification must have a trans_id, service and status")
+	}
+
+	notification := &WebhookNotification{
+		TransactionID: payload.TransactionID,
+		Service:       strings.ToUpper(payload.Details.Service),
+		Status:        payload.Details.Status,
+		Body:          append(json.RawMessage(nil), body...),
+	}
+	notification.DeliveryID = notification.Service + ":" + notification.TransactionID + ":" + notification.Status
+
+	return notification, nil
+}
@@ -0,0 +1,284 @@
+package mobilenig
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NdoleStudio/mobilenig-go/internal/stubs"
+	"github.com/stretchr/testify/assert"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func signWebhookBody(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendWebhook(handler http.Handler, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/mobilenig", strings.NewReader(body))
+	req.RemoteAddr = "41.203.10.5:43210"
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res
+}
+
+func TestNewWebhookHandler_RequiresVerification(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Act
+	_, err := NewWebhookHandler()
+	_, allowlistErr := NewWebhookHandler(WithWebhookIPAllowlist("not-an-ip"))
+
+	// Assert
+	assert.Error(t, err)
+	assert.Error(t, allowlistErr)
+}
+
+func TestWebhookHandler_DispatchesDStvTransactions(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	handler, err := NewWebhookHandler(WithWebhookSecret(testWebhookSecret))
+	assert.NoError(t, err)
+
+	var transactions []*DStvTransaction
+	handler.HandleDStv(func(ctx context.Context, transaction *DStvTransaction) error {
+		transactions = append(transactions, transaction)
+		return nil
+	})
+
+	var notifications []*WebhookNotification
+	handler.Handle("dstv", func(ctx context.Context, notification *WebhookNotification) error {
+		notifications = append(notifications, notification)
+		return nil
+	})
+
+	body := stubs.PayDstvBillResponse()
+	headers := map[string]string{WebhookSignatureHeader: "sha256=" + signWebhookBody(testWebhookSecret, body)}
+
+	// Act
+	first := sendWebhook(handler, body, headers)
+	headers["X-MobileNig-Delivery"] = "replayed-delivery"
+	second := sendWebhook(handler, body, headers)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusOK, second.Code)
+
+	assert.Equal(t, 1, len(transactions))
+	assert.Equal(t, "122790223", transactions[0].TransactionID)
+	assert.Equal(t, "4131953321", transactions[0].Details.SmartcardNumber)
+	assert.Equal(t, "SUCCESSFUL", transactions[0].Details.Status)
+
+	assert.Equal(t, 1, len(notifications))
+	assert.Equal(t, "DSTV:122790223:SUCCESSFUL", notifications[0].DeliveryID)
+	assert.Equal(t, LedgerStatusSucceeded, notifications[0].LedgerStatus())
+}
+
+func TestWebhookHandler_DispatchesOtherServices(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	handler, err := NewWebhookHandler(WithWebhookIPAllowlist("41.203.0.0/16"))
+	assert.NoError(t, err)
+
+	var notification *WebhookNotification
+	handler.Handle("ELECTRICITY", func(ctx context.Context, received *WebhookNotification) error {
+		notification = received
+		return nil
+	})
+
+	body := `{"trans_id":"elec-1","details":{"service":"electricity","status":"FAILED","token":""}}`
+
+	// Act
+	res := sendWebhook(handler, body, map[string]string{"X-MobileNig-Delivery": "delivery-1"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "ELECTRICITY:elec-1:FAILED", notification.DeliveryID)
+	assert.Equal(t, "elec-1", notification.TransactionID)
+	assert.Equal(t, "ELECTRICITY", notification.Service)
+	assert.Equal(t, LedgerStatusFailed, notification.LedgerStatus())
+	assert.JSONEq(t, body, string(notification.Body))
+}
+
+func TestWebhookHandler_RejectsUnverifiedSenders(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var rejections []error
+	handler, err := NewWebhookHandler(
+		WithWebhookSecret(testWebhookSecret),
+		WithWebhookIPAllowlist("10.0.0.1", "41.203.10.5"),
+		WithWebhookErrorHandler(func(req *http.Request, err error) {
+			rejections = append(rejections, err)
+		}),
+	)
+	assert.NoError(t, err)
+
+	called := false
+	handler.HandleDStv(func(ctx context.Context, transaction *DStvTransaction) error {
+		called = true
+		return nil
+	})
+
+	body := stubs.PayDstvBillResponse()
+
+	// Act
+	missing := sendWebhook(handler, body, nil)
+	invalid := sendWebhook(handler, body, map[string]string{WebhookSignatureHeader: signWebhookBody("wrong-secret", body)})
+	tampered := sendWebhook(handler, strings.Replace(body, "790", "1", 1), map[string]string{WebhookSignatureHeader: signWebhookBody(testWebhookSecret, body)})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.RemoteAddr = "192.168.1.1:43210"
+	req.Header.Set(WebhookSignatureHeader, signWebhookBody(testWebhookSecret, body))
+	forbidden := httptest.NewRecorder()
+	handler.ServeHTTP(forbidden, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, missing.Code)
+	assert.Equal(t, http.StatusUnauthorized, invalid.Code)
+	assert.Equal(t, http.StatusUnauthorized, tampered.Code)
+	assert.Equal(t, http.StatusForbidden, forbidden.Code)
+	assert.Equal(t, 4, len(rejections))
+	assert.False(t, called)
+}
+
+func TestWebhookHandler_RejectsInvalidNotifications(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	handler, err := NewWebhookHandler(WithWebhookIPAllowlist("41.203.10.5"))
+	assert.NoError(t, err)
+
+	// Act
+	invalidJSON := sendWebhook(handler, `{`, nil)
+	missingStatus := sendWebhook(handler, `{"trans_id":"1","details":{"service":"DSTV"}}`, nil)
+	wrongMethod := httptest.NewRecorder()
+	handler.ServeHTTP(wrongMethod, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, invalidJSON.Code)
+	assert.Equal(t, http.StatusBadRequest, missingStatus.Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, wrongMethod.Code)
+}
+
+func TestWebhookHandler_FailedDeliveriesAreRetried(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	handler, err := NewWebhookHandler(WithWebhookIPAllowlist("41.203.10.5"))
+	assert.NoError(t, err)
+
+	calls := 0
+	handler.HandleDStv(func(ctx context.Context, transaction *DStvTransaction) error {
+		calls++
+		if calls == 1 {
+			return errors.New("database is down")
+		}
+		return nil
+	})
+
+	body := stubs.PayDstvBillResponse()
+
+	// Act
+	failed := sendWebhook(handler, body, nil)
+	retried := sendWebhook(handler, body, nil)
+	duplicate := sendWebhook(handler, body, nil)
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, failed.Code)
+	assert.Equal(t, http.StatusOK, retried.Code)
+	assert.Equal(t, http.StatusOK, duplicate.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestWebhookHandler_ConcurrentDeliveriesAreRetried(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	handler, err := NewWebhookHandler(WithWebhookIPAllowlist("41.203.10.5"))
+	assert.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	handler.HandleDStv(func(ctx context.Context, transaction *DStvTransaction) error {
+		calls++
+		if calls == 1 {
+			close(started)
+			<-release
+			return errors.New("database is down")
+		}
+		return nil
+	})
+
+	body := stubs.PayDstvBillResponse()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- sendWebhook(handler, body, nil)
+	}()
+	<-started
+
+	// Act
+	concurrent := sendWebhook(handler, body, nil)
+	close(release)
+	failed := <-done
+	retried := sendWebhook(handler, body, nil)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, concurrent.Code)
+	assert.Equal(t, http.StatusInternalServerError, failed.Code)
+	assert.Equal(t, http.StatusOK, retried.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestWebhookHandler_UnhandledServicesAreRetried(t *testing.T) {
+	// Setup
+	t.Parallel()
+
+	// Arrange
+	var reported []error
+	handler, err := NewWebhookHandler(
+		WithWebhookIPAllowlist("41.203.10.5"),
+		WithWebhookErrorHandler(func(req *http.Request, err error) {
+			reported = append(reported, err)
+		}),
+	)
+	assert.NoError(t, err)
+
+	body := stubs.PayDstvBillResponse()
+
+	// Act
+	unhandled := sendWebhook(handler, body, nil)
+	handler.HandleDStv(func(ctx context.Context, transaction *DStvTransaction) error {
+		return nil
+	})
+	handled := sendWebhook(handler, body, nil)
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, unhandled.Code)
+	assert.Equal(t, http.StatusOK, handled.Code)
+	assert.Equal(t, 1, len(reported))
+	assert.True(t, errors.Is(reported[0], ErrNoWebhookHandler))
+}